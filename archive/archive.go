@@ -0,0 +1,303 @@
+// Package archive saves everything in a class to a directory, so it outlives
+// the class on Piazza. An archive is laid out as:
+//
+//	manifest.json             what the archive holds; written last
+//	network.json              the class
+//	posts/<nr>.json           each post with its replies, history and change
+//	                          log, as content.get returns it
+//	posts/<nr>.meta.json      the post's manifest entry
+//	attachments/<nr>/<name>   files the posts link to
+//	resources/resources.json  the class resources
+//	resources/<id>/<name>     files the resources link to
+//
+// Writing an archive again picks up where an interrupted run left off: posts
+// that haven't changed since they were saved and files that were already
+// downloaded are skipped. Posts deleted from Piazza stay in the archive,
+// marked as deleted.
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/render"
+	"github.com/pkg/errors"
+)
+
+// Version is the version of the archive layout written by this package.
+const Version = 1
+
+// DefaultConcurrency is the number of posts fetched at once when
+// Options.Concurrency is unset.
+const DefaultConcurrency = piazza.DefaultSyncConcurrency
+
+// The files and directories of an archive.
+const (
+	ManifestFile  = "manifest.json"
+	NetworkFile   = "network.json"
+	PostsDir      = "posts"
+	AttachmentDir = "attachments"
+	ResourcesDir  = "resources"
+	ResourcesFile = "resources.json"
+)
+
+// Options controls Write.
+type Options struct {
+	// Concurrency is how many posts are fetched at once.
+	Concurrency int
+	// Force refetches posts and files that are already in the archive.
+	Force bool
+	// ExternalLinks also downloads resources that link outside of Piazza.
+	ExternalLinks bool
+	// Logf, if set, is called with progress messages.
+	Logf func(format string, args ...interface{})
+}
+
+func (o Options) logf(format string, args ...interface{}) {
+	if o.Logf != nil {
+		o.Logf(format, args...)
+	}
+}
+
+// Manifest describes an archive. BaseURL is the site the class was archived
+// from, which relative links in post content point at.
+type Manifest struct {
+	Version   int             `json:"version"`
+	Network   string          `json:"network"`
+	Name      string          `json:"name"`
+	BaseURL   string          `json:"base_url,omitempty"`
+	Archived  piazza.Time     `json:"archived"`
+	Posts     []PostEntry     `json:"posts"`
+	Resources []ResourceEntry `json:"resources"`
+}
+
+// PostEntry is a post in the manifest.
+type PostEntry struct {
+	Nr           int         `json:"nr"`
+	ID           string      `json:"id"`
+	Subject      string      `json:"subject"`
+	Folders      []string    `json:"folders"`
+	LastActivity piazza.Time `json:"last_activity"`
+	// File is the path of the post relative to the archive.
+	File        string `json:"file"`
+	Attachments []File `json:"attachments"`
+	// State is the feed's view of the post when it was saved.
+	State piazza.PostState `json:"state"`
+	// Deleted is set once the post is gone from Piazza. The archive keeps
+	// its last saved copy.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// ResourceEntry is a class resource in the manifest.
+type ResourceEntry struct {
+	ID      string `json:"id"`
+	Subject string `json:"subject"`
+	Type    string `json:"type"`
+	URL     string `json:"url"`
+	// File is set if the resource was downloaded.
+	File *File `json:"file,omitempty"`
+}
+
+// File is a downloaded file.
+type File struct {
+	Name string `json:"name"`
+	// URL is the link to the file as it appears on Piazza.
+	URL string `json:"url"`
+	// Path is where the file is relative to the archive. It's empty if the
+	// download failed.
+	Path string `json:"path,omitempty"`
+	// Error is why the download failed.
+	Error string `json:"error,omitempty"`
+}
+
+// PostFile returns the path of post number nr relative to the archive.
+func PostFile(nr int) string {
+	return path.Join(PostsDir, strconv.Itoa(nr)+".json")
+}
+
+// metaFile returns the path of the manifest entry of post number nr relative
+// to the archive.
+func metaFile(nr int) string {
+	return path.Join(PostsDir, strconv.Itoa(nr)+metaSuffix)
+}
+
+const metaSuffix = ".meta.json"
+
+// ReadManifest reads the manifest of the archive in dir.
+func ReadManifest(dir string) (Manifest, error) {
+	var m Manifest
+	if err := readJSON(filepath.Join(dir, ManifestFile), &m); err != nil {
+		return Manifest{}, err
+	}
+	if m.Version > Version {
+		return Manifest{}, errors.Errorf("archive %q has version %d; only up to %d is supported", dir, m.Version, Version)
+	}
+	return m, nil
+}
+
+// ReadPost reads post number nr from the archive in dir.
+func ReadPost(dir string, nr int) (piazza.Post, error) {
+	var p piazza.Post
+	err := readJSON(filepath.Join(dir, filepath.FromSlash(PostFile(nr))), &p)
+	return p, err
+}
+
+func readJSON(file string, v interface{}) error {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(json.Unmarshal(buf, v), "reading %q", file)
+}
+
+// writeFile writes a file by renaming a temporary file into place, so an
+// interrupted write never leaves a partial file behind.
+func writeFile(file string, write func(w io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(file), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), file); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+func writeJSON(file string, v interface{}) error {
+	return writeFile(file, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	})
+}
+
+func exists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
+
+// safeName makes a file name from a link safe to use in the archive.
+func safeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".tmp-") {
+		return "file"
+	}
+	return name
+}
+
+// fatal reports whether an error should stop the archive rather than be
+// recorded against the file that failed.
+func fatal(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || errors.Is(err, piazza.ErrNotLoggedIn)
+}
+
+// resources archives the class resources and the files they link to.
+func (d *Dir) resources(ctx context.Context, n piazza.Network) ([]ResourceEntry, error) {
+	resources, err := d.c.FetchResourcesContext(ctx, d.c.ResourceURL(n))
+	if err != nil {
+		return nil, err
+	}
+	if err := writeJSON(filepath.Join(d.dir, ResourcesDir, ResourcesFile), resources); err != nil {
+		return nil, err
+	}
+
+	entries := []ResourceEntry{}
+	for _, r := range resources {
+		entry := ResourceEntry{
+			ID:      r.ID,
+			Subject: r.Subject,
+			Type:    r.Config.ResourceType,
+			URL:     r.Content,
+		}
+		name, hosted := render.AttachmentName(r.Content)
+		if !hosted && d.opts.ExternalLinks {
+			if u, err := url.Parse(r.Content); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+				name = path.Base(u.Path)
+				if name == "/" || name == "." {
+					name = "index.html"
+				}
+				hosted = true
+			}
+		}
+		if hosted && len(r.ID) > 0 {
+			f, err := d.download(ctx, name, r.Content, path.Join(ResourcesDir, safeName(r.ID), safeName(name)))
+			if err != nil {
+				return nil, err
+			}
+			entry.File = &f
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Write archives the class nid to dir, creating it if needed. If dir holds an
+// earlier or interrupted archive of the class, only posts that changed since
+// are fetched, using Client.Sync. The manifest is written once everything else
+// has been.
+func Write(ctx context.Context, c *piazza.Client, nid, dir string, opts Options) (Manifest, error) {
+	n, err := c.Network(ctx, nid)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if err := writeJSON(filepath.Join(dir, NetworkFile), n); err != nil {
+		return Manifest{}, err
+	}
+
+	d := NewDir(c, dir, opts)
+	m := Manifest{
+		Version:  Version,
+		Network:  nid,
+		Name:     n.Name,
+		BaseURL:  c.BaseURL(),
+		Archived: piazza.Time{Time: time.Now().UTC().Truncate(time.Second)},
+	}
+	if m.Resources, err = d.resources(ctx, n); err != nil {
+		return Manifest{}, err
+	}
+	summary, err := c.SyncWithOptions(ctx, nid, d, piazza.SyncOptions{
+		Concurrency: opts.Concurrency,
+		Force:       opts.Force,
+	})
+	if err != nil {
+		return Manifest{}, err
+	}
+	opts.logf("%s: %d new, %d updated, %d deleted and %d unchanged posts, %d resources",
+		nid, len(summary.New), len(summary.Updated), len(summary.Deleted), summary.Unchanged, len(m.Resources))
+	if m.Posts, err = d.Entries(); err != nil {
+		return Manifest{}, err
+	}
+
+	if err := writeJSON(filepath.Join(dir, ManifestFile), m); err != nil {
+		return Manifest{}, err
+	}
+	return m, nil
+}
@@ -0,0 +1,151 @@
+package archive_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/archive"
+	"github.com/d4l3k/piazza-api/piazzatest"
+)
+
+// newFake returns a client for a student in class n1, which has a question
+// with attachments, a note and two resources.
+func newFake(t *testing.T) *piazza.Client {
+	f, student, _ := piazzatest.NewClass(t, `{"id": "n1", "name": "Computer Networking",
+		"school_ext": "ubc.ca", "term": "Winter Term 1 2016", "short_number": "cpsc317"}`)
+	notes := f.AddFile("attach/n1/u1/notes.pdf", []byte("%PDF notes"))
+	f.AddPosts("n1", `{
+		"id": "p1",
+		"type": "question",
+		"created": "2016-09-06T20:32:57Z",
+		"history": [{"subject": "Notes", "created": "2016-09-06T20:32:57Z",
+			"content": "<p>See <a href=\"`+notes+`\">the notes</a> and <a href=\"/redirect/s3?bucket=uploads&amp;prefix=attach%2Fgone.png\">this</a></p>"}]
+	}`, `{"id": "p2", "type": "note", "created": "2016-09-07T20:32:57Z",
+		"history": [{"subject": "Hi", "created": "2016-09-07T20:32:57Z"}]}`)
+
+	var r piazza.Resource
+	r.ID = "r1"
+	r.Subject = "Syllabus"
+	r.Content = f.AddFile("resources/n1/syllabus.pdf", []byte("%PDF syllabus"))
+	r.Config.ResourceType = "file"
+	f.AddResource("n1", r)
+	r.ID = "r2"
+	r.Content = "https://fn.lc/reading"
+	r.Config.ResourceType = "link"
+	f.AddResource("n1", r)
+	return student
+}
+
+func readFile(t *testing.T, dir, file string) string {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf)
+}
+
+func TestWrite(t *testing.T) {
+	c := newFake(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	m, err := archive.Write(ctx, c, "n1", dir, archive.Options{Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != archive.Version || m.Network != "n1" || m.Name != "Computer Networking" || m.BaseURL != c.BaseURL() || len(m.Posts) != 2 {
+		t.Fatalf("manifest = %+v", m)
+	}
+	if read, err := archive.ReadManifest(dir); err != nil || len(read.Posts) != 2 {
+		t.Errorf("ReadManifest() = %+v, %v", read, err)
+	}
+
+	p1 := m.Posts[0]
+	if p1.Nr != 1 || p1.ID != "p1" || p1.File != "posts/1.json" || len(p1.Attachments) != 2 {
+		t.Fatalf("post entry = %+v", p1)
+	}
+	if f := p1.Attachments[0]; f.Name != "notes.pdf" || f.Path != "attachments/1/notes.pdf" || len(f.Error) > 0 {
+		t.Errorf("attachment = %+v", f)
+	}
+	if got := readFile(t, dir, p1.Attachments[0].Path); got != "%PDF notes" {
+		t.Errorf("attachment content = %q", got)
+	}
+	if f := p1.Attachments[1]; f.Name != "gone.png" || len(f.Path) > 0 || len(f.Error) == 0 {
+		t.Errorf("missing attachment = %+v; expected an error", f)
+	}
+	post, err := archive.ReadPost(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.ID != "p1" || len(post.History) != 1 {
+		t.Errorf("ReadPost() = %+v", post)
+	}
+
+	if len(m.Resources) != 2 || m.Resources[0].File == nil || m.Resources[1].File != nil {
+		t.Fatalf("resources = %+v", m.Resources)
+	}
+	if got := readFile(t, dir, m.Resources[0].File.Path); got != "%PDF syllabus" {
+		t.Errorf("resource content = %q", got)
+	}
+	for _, file := range []string{archive.NetworkFile, "resources/resources.json"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestWriteResume(t *testing.T) {
+	c := newFake(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	if _, err := archive.Write(ctx, c, "n1", dir, archive.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mark the saved files as old, then simulate a run that was interrupted
+	// while saving the note, and change the question.
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, file := range []string{"posts/1.json", "posts/2.json", "attachments/1/notes.pdf"} {
+		if err := os.Chtimes(filepath.Join(dir, file), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(filepath.Join(dir, "posts", "2.meta.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AddFollowup(ctx, "n1", "p1", "bump"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := archive.Write(ctx, c, "n1", dir, archive.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Posts) != 2 {
+		t.Fatalf("manifest has %d posts; not 2", len(m.Posts))
+	}
+	modified := func(file string) bool {
+		fi, err := os.Stat(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return !fi.ModTime().Equal(old)
+	}
+	if !modified("posts/1.json") {
+		t.Error("the changed post wasn't refetched")
+	}
+	if post, err := archive.ReadPost(dir, 1); err != nil || len(post.Children) != 1 {
+		t.Errorf("ReadPost() = %+v, %v; expected the followup", post, err)
+	}
+	if !modified("posts/2.json") {
+		t.Error("the partly saved post wasn't fetched again")
+	}
+	if modified("attachments/1/notes.pdf") {
+		t.Error("the attachment was downloaded again")
+	}
+}
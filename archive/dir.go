@@ -0,0 +1,192 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/render"
+	"github.com/pkg/errors"
+)
+
+// Dir is a piazza.Store that keeps the posts of one class in an archive
+// directory. Saving a post downloads its attachments and then writes the post
+// and its manifest entry, so an interrupted sync never leaves a post behind
+// that looks complete.
+type Dir struct {
+	c    *piazza.Client
+	dir  string
+	opts Options
+}
+
+var _ piazza.Store = (*Dir)(nil)
+
+// NewDir returns a store for the archive in dir that downloads files with c.
+func NewDir(c *piazza.Client, dir string, opts Options) *Dir {
+	return &Dir{c: c, dir: dir, opts: opts}
+}
+
+// download fetches rawurl to file, a path relative to the archive, unless it's
+// already there. Failed downloads are recorded in the returned File.
+func (d *Dir) download(ctx context.Context, name, rawurl, file string) (File, error) {
+	f := File{Name: name, URL: rawurl, Path: file}
+	full := filepath.Join(d.dir, filepath.FromSlash(file))
+	if !d.opts.Force && exists(full) {
+		return f, nil
+	}
+	err := writeFile(full, func(out io.Writer) error {
+		return d.c.Download(ctx, rawurl, out)
+	})
+	if err != nil {
+		if fatal(ctx, err) {
+			return File{}, err
+		}
+		d.opts.logf("downloading %s: %v", rawurl, err)
+		f.Path = ""
+		f.Error = err.Error()
+	}
+	return f, nil
+}
+
+// Activity returns the last time anything in a post changed.
+func Activity(p piazza.Post) piazza.Time {
+	last := p.Created
+	later := func(t piazza.Time) {
+		if t.After(last.Time) {
+			last = t
+		}
+	}
+	for _, h := range p.History {
+		later(h.Created)
+	}
+	for _, c := range p.ChangeLog {
+		later(c.When)
+	}
+	for _, child := range p.Children {
+		later(Activity(child))
+	}
+	return last
+}
+
+// postContent returns the HTML of every revision of a post and its replies.
+// Followups and feedback keep their text in Subject.
+func postContent(p piazza.Post) []string {
+	var content []string
+	if len(p.Subject) > 0 {
+		content = append(content, p.Subject)
+	}
+	for _, h := range p.History {
+		content = append(content, h.Content)
+	}
+	for _, child := range p.Children {
+		content = append(content, postContent(child)...)
+	}
+	return content
+}
+
+// Entries returns the manifest entries of every post in the archive, including
+// deleted ones, ordered by number.
+func (d *Dir) Entries() ([]PostEntry, error) {
+	files, err := ioutil.ReadDir(filepath.Join(d.dir, PostsDir))
+	if os.IsNotExist(err) {
+		return []PostEntry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	entries := []PostEntry{}
+	for _, fi := range files {
+		if !strings.HasSuffix(fi.Name(), metaSuffix) {
+			continue
+		}
+		var e PostEntry
+		if err := readJSON(filepath.Join(d.dir, PostsDir, fi.Name()), &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Nr < entries[j].Nr })
+	return entries, nil
+}
+
+// PostStates implements piazza.Store.
+func (d *Dir) PostStates(ctx context.Context, nid string) (map[string]piazza.PostState, error) {
+	entries, err := d.Entries()
+	if err != nil {
+		return nil, err
+	}
+	states := map[string]piazza.PostState{}
+	for _, e := range entries {
+		if !e.Deleted {
+			states[e.ID] = e.State
+		}
+	}
+	return states, nil
+}
+
+// PutPost implements piazza.Store.
+func (d *Dir) PutPost(ctx context.Context, nid string, item piazza.FeedItem, p piazza.Post) error {
+	entry := PostEntry{
+		Nr:           item.Nr,
+		ID:           p.ID,
+		Subject:      item.Subject,
+		Folders:      p.Folders,
+		LastActivity: item.LastActivity(),
+		File:         PostFile(item.Nr),
+		Attachments:  []File{},
+		State:        item.State(),
+	}
+	if last := Activity(p); last.After(entry.LastActivity.Time) {
+		entry.LastActivity = last
+	}
+
+	names := map[string]string{}
+	r := render.Options{BaseURL: d.c.BaseURL()}
+	for _, content := range postContent(p) {
+		for _, a := range r.Attachments(content) {
+			name := safeName(a.Name)
+			if href, ok := names[name]; ok {
+				if href == a.Href {
+					continue
+				}
+				name = fmt.Sprintf("%d-%s", len(names), name)
+			}
+			names[name] = a.Href
+			f, err := d.download(ctx, a.Name, a.Href, path.Join(AttachmentDir, strconv.Itoa(item.Nr), name))
+			if err != nil {
+				return err
+			}
+			entry.Attachments = append(entry.Attachments, f)
+		}
+	}
+
+	if err := writeJSON(filepath.Join(d.dir, filepath.FromSlash(entry.File)), p); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(d.dir, filepath.FromSlash(metaFile(item.Nr))), entry); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeletePost implements piazza.Store by marking the post as deleted. Its saved
+// copy is kept.
+func (d *Dir) DeletePost(ctx context.Context, nid string, state piazza.PostState) error {
+	file := filepath.Join(d.dir, filepath.FromSlash(metaFile(state.Nr)))
+	var entry PostEntry
+	if err := readJSON(file, &entry); err != nil {
+		return err
+	}
+	if entry.ID != state.ID {
+		return errors.Errorf("post %d in %q is %q, not %q", state.Nr, d.dir, entry.ID, state.ID)
+	}
+	entry.Deleted = true
+	return writeJSON(file, entry)
+}
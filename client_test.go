@@ -0,0 +1,278 @@
+package piazza_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/piazzatest"
+)
+
+// newFake returns a fake site seeded with one user in one class with a couple
+// of posts, and a client logged into it.
+func newFake(t *testing.T) (*piazzatest.Server, *piazza.Client) {
+	s := piazzatest.NewServer()
+	t.Cleanup(s.Close)
+
+	uid := s.AddUser(piazzatest.User{
+		ID:       "u1",
+		Email:    "student@example.com",
+		Password: "hunter2",
+		Name:     "Some Student",
+		EmailPrefs: piazza.EmailPrefs{
+			"n1": {New: "real-time", Updates: "real-time"},
+		},
+	})
+	s.AddNetwork(piazza.Network{
+		ID:          "n1",
+		Name:        "Computer Networking",
+		SchoolExt:   "ubc.ca",
+		Term:        "Winter Term 1 2016",
+		ShortNumber: "cpsc317",
+		Folders:     []string{"hw1", "logistics"},
+	}, uid)
+
+	s.AddPost("n1", mustPost(t, `{
+		"id": "p1",
+		"type": "question",
+		"folders": ["hw1"],
+		"created": "2016-09-06T20:32:57Z",
+		"history": [{
+			"anon": "no",
+			"content": "<p>Check https://fn.lc/duck please</p>",
+			"created": "2016-09-06T20:32:57Z",
+			"subject": "Question 1",
+			"uid": "u1"
+		}]
+	}`))
+	s.AddPost("n1", mustPost(t, `{"id": "p2", "type": "note", "created": "2016-09-07T20:32:57Z"}`))
+
+	var r piazza.Resource
+	r.ID = "r1"
+	r.Subject = "Reading"
+	r.Content = "https://fn.lc/reading"
+	s.AddResource("n1", r)
+
+	c, err := piazza.MakeClientWithOptions("student@example.com", "hunter2", s.ClientOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, c
+}
+
+func mustPost(t *testing.T, js string) piazza.Post {
+	var p piazza.Post
+	if err := json.Unmarshal([]byte(js), &p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestFakeLogin(t *testing.T) {
+	s, _ := newFake(t)
+	_, err := piazza.MakeClientWithOptions("student@example.com", "wrong", s.ClientOptions())
+	if err == nil || !strings.Contains(err.Error(), "incorrect") {
+		t.Errorf("MakeClient with a bad password = %v; expected an error", err)
+	}
+}
+
+func TestFakeClient(t *testing.T) {
+	s, c := newFake(t)
+
+	status, err := c.UserStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Result.Networks) != 1 || status.Result.Networks[0].ID != "n1" {
+		t.Fatalf("status.Result.Networks = %+v", status.Result.Networks)
+	}
+
+	feed, err := c.Feed("n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Result.Feed) != 2 {
+		t.Fatalf("len(feed.Result.Feed) = %d; not 2", len(feed.Result.Feed))
+	}
+
+	post, err := c.Content("n1", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.ID != "p1" || post.History[0].Subject != "Question 1" {
+		t.Errorf("c.Content(n1, 1) = %+v", post)
+	}
+
+	resources, err := c.FetchResources(c.ResourceURL(status.Result.Networks[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 1 || resources[0].ID != "r1" {
+		t.Errorf("c.FetchResources() = %+v", resources)
+	}
+
+	if err := c.OptOutOfEmails(); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := s.User("u1")
+	if got := u.EmailPrefs["n1"].New; got != "no-emails" {
+		t.Errorf("EmailPrefs[n1].New = %q; not %q", got, "no-emails")
+	}
+}
+
+func TestFakeHTMLWrapper(t *testing.T) {
+	_, c := newFake(t)
+	w := c.HTMLWrapper()
+	cases := []struct {
+		url      string
+		contains []string
+	}{
+		{"piazza://", []string{`<a href="piazza://n1">`}},
+		{"piazza://n1", []string{"https://fn.lc/reading", `<a href="piazza://n1/p1">`, `<a href="piazza://n1/p2">`}},
+		{"piazza://n1/p1", []string{"Check", `<a href="https://fn.lc/duck">`}},
+	}
+	for _, c := range cases {
+		out, err := w.Get(c.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range c.contains {
+			if !strings.Contains(out, want) {
+				t.Errorf("w.Get(%q) = %q; missing %q", c.url, out, want)
+			}
+		}
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	s, c := newFake(t)
+	s.AddNetwork(piazza.Network{ID: "other"})
+
+	loggedOut, err := piazza.NewClient(s.ClientOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"bad cid", errOf(c.Content("n1", "nope")), piazza.ErrNotFound},
+		{"not enrolled", errOf(c.Content("other", "1")), piazza.ErrPermissionDenied},
+		{"logged out", errOf(loggedOut.UserStatus()), piazza.ErrNotLoggedIn},
+	}
+	for _, c := range cases {
+		if !errors.Is(c.err, c.want) {
+			t.Errorf("%s: err = %v; not %v", c.name, c.err, c.want)
+		}
+		var apiErr *piazza.APIError
+		if !errors.As(c.err, &apiErr) || apiErr.Method != "content.get" && apiErr.Method != "user.status" {
+			t.Errorf("%s: err = %#v; expected an *APIError", c.name, c.err)
+		}
+	}
+}
+
+func errOf(_ interface{}, err error) error {
+	return err
+}
+
+func TestRenewSession(t *testing.T) {
+	s, _ := newFake(t)
+
+	renewals := 0
+	opts := s.ClientOptions()
+	opts.RenewSession = true
+	opts.OnRenew = func(err error) {
+		if err != nil {
+			t.Errorf("OnRenew(%v)", err)
+		}
+		renewals++
+	}
+	c, err := piazza.MakeClientWithOptions("student@example.com", "hunter2", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.ExpireSessions()
+	if _, err := c.UserStatus(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Content("n1", "p1"); err != nil {
+		t.Fatal(err)
+	}
+	if renewals != 1 {
+		t.Errorf("renewals = %d; not 1", renewals)
+	}
+
+	// Without RenewSession the error surfaces.
+	s2, plain := newFake(t)
+	s2.ExpireSessions()
+	if _, err := plain.UserStatus(); !errors.Is(err, piazza.ErrNotLoggedIn) {
+		t.Errorf("UserStatus() = %v; not %v", err, piazza.ErrNotLoggedIn)
+	}
+}
+
+func TestSaveRestoreSession(t *testing.T) {
+	s, c := newFake(t)
+	if _, err := c.UserStatus(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := c.SaveSessionFile(path); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("session file mode = %o; not 600", perm)
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		Cookies []struct {
+			URL      string `json:"url"`
+			Name     string
+			Path     string
+			Expires  time.Time
+			HttpOnly bool
+		} `json:"cookies"`
+	}
+	if err := json.Unmarshal(buf, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Cookies) != 1 {
+		t.Fatalf("saved cookies = %+v; want the session cookie", saved.Cookies)
+	}
+	if sc := saved.Cookies[0]; sc.Name != piazzatest.SessionCookie || !strings.HasPrefix(sc.URL, s.URL) ||
+		sc.Path != "/" || sc.Expires.IsZero() || !sc.HttpOnly {
+		t.Errorf("saved cookie = %+v; want its URL, path, expiry and HttpOnly", sc)
+	}
+
+	restored, err := piazza.RestoreClientFile(path, s.ClientOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.CheckSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.Content("n1", "p1"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.ExpireSessions()
+	if err := restored.CheckSession(context.Background()); !errors.Is(err, piazza.ErrNotLoggedIn) {
+		t.Errorf("CheckSession() = %v; not %v", err, piazza.ErrNotLoggedIn)
+	}
+}
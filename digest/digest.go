@@ -0,0 +1,192 @@
+// Package digest gathers what changed in a user's classes since some time
+// into one email, a quieter stand-in for Piazza's own notifications:
+//
+//	d, err := digest.Gather(ctx, c, lastRun)
+//	msg, err := digest.Message(d, digest.Header{From: from, To: to})
+//	err = digest.SMTP{Addr: "smtp.example.com:587"}.Send(ctx, from, to, msg)
+package digest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/pkg/errors"
+)
+
+// Unfiled is the name of the folder posts outside the class's folders go in.
+const Unfiled = "Other posts"
+
+// Digest is the posts that changed in a user's classes over some time.
+type Digest struct {
+	Since time.Time
+	// Until is the latest activity in the digest, or Since if there's none.
+	// It comes from Piazza rather than the local clock, so the next digest
+	// can start from it without missing or repeating posts.
+	Until time.Time
+	// Classes are those with posts that changed, in the order Piazza lists
+	// them.
+	Classes []Class
+}
+
+// Len returns the number of posts in the digest.
+func (d Digest) Len() int {
+	n := 0
+	for _, c := range d.Classes {
+		for _, f := range c.Folders {
+			n += len(f.Posts)
+		}
+	}
+	return n
+}
+
+// Class is the posts that changed in a class, by folder.
+type Class struct {
+	ID   string
+	Name string
+	// Folders are in the order the class lists them, followed by Unfiled.
+	// Posts in several folders are only in the first.
+	Folders []Folder
+}
+
+// Folder is the posts that changed in a folder, most recent first.
+type Folder struct {
+	Name  string
+	Posts []Post
+}
+
+// Post is a post that changed.
+type Post struct {
+	Nr      int
+	Subject string
+	Snippet string
+	URL     string
+	// New is whether the post was written since the digest started rather
+	// than just changed.
+	New bool
+	// Unanswered is whether the post is waiting on an answer or has
+	// unresolved followups.
+	Unanswered bool
+	Updated    time.Time
+}
+
+// Gather returns the posts that changed since since in every class the user
+// of c is in.
+func Gather(ctx context.Context, c *piazza.Client, since time.Time) (Digest, error) {
+	d := Digest{Since: since, Until: since}
+	status, err := c.UserStatusContext(ctx)
+	if err != nil {
+		return Digest{}, err
+	}
+	for _, n := range status.Result.Networks {
+		class, err := gatherClass(ctx, c, n, since)
+		if err != nil {
+			return Digest{}, errors.Wrapf(err, "gathering %s", n.ID)
+		}
+		for _, f := range class.Folders {
+			for _, p := range f.Posts {
+				if p.Updated.After(d.Until) {
+					d.Until = p.Updated
+				}
+			}
+		}
+		if len(class.Folders) > 0 {
+			d.Classes = append(d.Classes, class)
+		}
+	}
+	return d, nil
+}
+
+func gatherClass(ctx context.Context, c *piazza.Client, n piazza.Network, since time.Time) (Class, error) {
+	class := Class{ID: n.ID, Name: className(n)}
+	byFolder := map[string][]Post{}
+	// Pinned posts come first whatever the sort, so the whole feed is read
+	// rather than stopping at the first old post.
+	it := c.FeedPages(ctx, n.ID, piazza.FeedOptions{})
+	for it.Next() {
+		item := it.Item()
+		updated := item.LastActivity().Time
+		if !updated.After(since) {
+			continue
+		}
+		p := Post{
+			Nr:         item.Nr,
+			Subject:    item.Subject,
+			Snippet:    strings.TrimSpace(item.ContentSnipet),
+			URL:        c.PostURL(n.ID, item.Nr),
+			Unanswered: item.IsUnanswered(),
+			Updated:    updated,
+		}
+		for _, entry := range item.Log {
+			if entry.Type == "create" {
+				p.New = entry.When.After(since)
+				break
+			}
+		}
+		folder := firstFolder(n.Folders, item.Folders)
+		byFolder[folder] = append(byFolder[folder], p)
+	}
+	if err := it.Err(); err != nil {
+		return Class{}, err
+	}
+
+	var names []string
+	for name := range byFolder {
+		names = append(names, name)
+	}
+	order := map[string]int{}
+	for i, name := range n.Folders {
+		order[name] = i + 1
+	}
+	order[Unfiled] = len(n.Folders) + 2
+	rank := func(name string) int {
+		if r, ok := order[name]; ok {
+			return r
+		}
+		// Folders the class doesn't list go after the ones it does.
+		return len(n.Folders) + 1
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if ri, rj := rank(names[i]), rank(names[j]); ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		posts := byFolder[name]
+		sort.SliceStable(posts, func(i, j int) bool { return posts[i].Updated.After(posts[j].Updated) })
+		class.Folders = append(class.Folders, Folder{Name: name, Posts: posts})
+	}
+	return class, nil
+}
+
+func className(n piazza.Network) string {
+	if len(n.CourseNumber) > 0 && len(n.Name) > 0 && n.CourseNumber != n.Name {
+		return n.CourseNumber + ": " + n.Name
+	}
+	if len(n.Name) > 0 {
+		return n.Name
+	}
+	if len(n.CourseNumber) > 0 {
+		return n.CourseNumber
+	}
+	return n.ID
+}
+
+// firstFolder returns the first folder in the class's order that a post is
+// in.
+func firstFolder(classFolders, folders []string) string {
+	for _, f := range classFolders {
+		for _, pf := range folders {
+			if f == pf {
+				return f
+			}
+		}
+	}
+	if len(folders) > 0 {
+		return folders[0]
+	}
+	return Unfiled
+}
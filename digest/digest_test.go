@@ -0,0 +1,358 @@
+package digest_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/digest"
+	"github.com/d4l3k/piazza-api/piazzatest"
+)
+
+var since = time.Date(2016, 9, 10, 0, 0, 0, 0, time.UTC)
+
+// newFake returns a client for a student in two classes: n1, with posts from
+// before and after since, and n2, where nothing happened since.
+func newFake(t *testing.T) *piazza.Client {
+	f := piazzatest.NewFixture(t)
+	f.AddClass(`{"id": "n1", "name": "Computer Networking", "course_number": "CPSC 317", "folders": ["hw1", "exam"]}`,
+		`{"id": "p1", "nr": 1, "type": "note", "folders": ["hw1"],
+			"history": [{"subject": "Old", "content": "Old news", "created": "2016-09-06T00:00:00Z"}],
+			"change_log": [{"type": "create", "when": "2016-09-06T00:00:00Z"}]}`,
+		`{"id": "p2", "nr": 2, "type": "question", "folders": ["exam"],
+			"history": [{"subject": "Exam room?", "content": "Where is it?", "created": "2016-09-12T00:00:00Z"}],
+			"change_log": [{"type": "create", "when": "2016-09-12T00:00:00Z"}]}`,
+		`{"id": "p3", "nr": 3, "type": "note", "folders": ["exam", "hw1"],
+			"history": [{"subject": "Checksums & carries", "content": "<p>Read this</p>", "created": "2016-09-01T00:00:00Z"}],
+			"change_log": [{"type": "create", "when": "2016-09-01T00:00:00Z"},
+				{"type": "followup", "when": "2016-09-13T00:00:00Z"}]}`,
+		`{"id": "p4", "nr": 4, "type": "note", "folders": ["lecture"],
+			"history": [{"subject": "Slides", "content": "Up", "created": "2016-09-11T00:00:00Z"}],
+			"change_log": [{"type": "create", "when": "2016-09-11T00:00:00Z"}]}`,
+		`{"id": "p5", "nr": 5, "type": "note",
+			"history": [{"subject": "Hi", "content": "Hello", "created": "2016-09-11T00:00:00Z"}],
+			"change_log": [{"type": "create", "when": "2016-09-11T00:00:00Z"}]}`)
+	f.AddClass(`{"id": "n2", "name": "Quiet Class"}`,
+		`{"id": "q1", "nr": 1, "type": "note",
+			"history": [{"subject": "Quiet", "content": "Shh", "created": "2016-09-01T00:00:00Z"}]}`)
+	return f.Student()
+}
+
+func TestGather(t *testing.T) {
+	c := newFake(t)
+	d, err := digest.Gather(context.Background(), c, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, class := range d.Classes {
+		for _, f := range class.Folders {
+			for _, p := range f.Posts {
+				got = append(got, fmt.Sprintf("%s/%s/@%d new=%t unanswered=%t", class.Name, f.Name, p.Nr, p.New, p.Unanswered))
+			}
+		}
+	}
+	want := []string{
+		"CPSC 317: Computer Networking/hw1/@3 new=false unanswered=false",
+		"CPSC 317: Computer Networking/exam/@2 new=true unanswered=true",
+		"CPSC 317: Computer Networking/lecture/@4 new=true unanswered=false",
+		"CPSC 317: Computer Networking/" + digest.Unfiled + "/@5 new=true unanswered=false",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("digest = %q; expected %q", got, want)
+	}
+	if d.Len() != 4 || d.Subject() != "Piazza digest: 4 posts in 1 class" {
+		t.Errorf("Len() = %d, Subject() = %q", d.Len(), d.Subject())
+	}
+	if url := d.Classes[0].Folders[0].Posts[0].URL; url != c.PostURL("n1", 3) {
+		t.Errorf("URL = %q", url)
+	}
+	if until := time.Date(2016, 9, 13, 0, 0, 0, 0, time.UTC); !d.Until.Equal(until) {
+		t.Errorf("Until = %s; expected %s", d.Until, until)
+	}
+
+	// The next digest starts where this one ended.
+	if d, err = digest.Gather(context.Background(), c, d.Until); err != nil {
+		t.Fatal(err)
+	}
+	if d.Len() != 0 || !d.Until.Equal(d.Since) {
+		t.Errorf("Len() = %d, Until = %s; expected nothing since %s", d.Len(), d.Until, d.Since)
+	}
+}
+
+// parts returns the decoded parts of a multipart/alternative email by type,
+// with plain line endings.
+func parts(t *testing.T, msg []byte) (*mail.Message, map[string]string) {
+	m, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil || typ != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", typ, err)
+	}
+	bodies := map[string]string{}
+	r := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err != nil {
+			break
+		}
+		buf, err := ioutil.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		typ, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		bodies[typ] = strings.Replace(string(buf), "\r\n", "\n", -1)
+	}
+	return m, bodies
+}
+
+func TestMessage(t *testing.T) {
+	d, err := digest.Gather(context.Background(), newFake(t), since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := digest.Message(d, digest.Header{From: "Piazza Bøt <bot@example.com>", To: []string{"a@example.com", "B <b@example.com>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, bodies := parts(t, msg)
+	if to, err := m.Header.AddressList("To"); err != nil || len(to) != 2 || to[0].Address != "a@example.com" || to[1].Name != "B" {
+		t.Errorf("To = %q, %v", m.Header.Get("To"), err)
+	}
+	if from, err := m.Header.AddressList("From"); err != nil || len(from) != 1 || from[0].Name != "Piazza Bøt" || from[0].Address != "bot@example.com" {
+		t.Errorf("From = %q, %v", m.Header.Get("From"), err)
+	}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject")); err != nil || subject != d.Subject() {
+		t.Errorf("Subject = %q, %v", subject, err)
+	}
+	text := bodies["text/plain"]
+	for _, want := range []string{"CPSC 317: Computer Networking\n\n  hw1\n    @3 Checksums & carries\n", "@2 Exam room? [new] [unanswered]"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text is missing %q:\n%s", want, text)
+		}
+	}
+	html := bodies["text/html"]
+	for _, want := range []string{"<h2", "Checksums &amp; carries", "<h3 style=\"margin: 0.8em 0 0.2em; color: #3e7aab\">exam</h3>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML is missing %q:\n%s", want, html)
+		}
+	}
+	if strings.Contains(text+html, "Quiet") || strings.Contains(text+html, "Old news") {
+		t.Errorf("digest has posts that didn't change")
+	}
+
+	// Addresses can't smuggle in headers.
+	for _, h := range []digest.Header{
+		{From: "bot@example.com\r\nBcc: c@example.com", To: []string{"a@example.com"}},
+		{From: "bot@example.com", To: []string{"a@example.com\r\nBcc: c@example.com"}},
+	} {
+		if _, err := digest.Message(d, h); err == nil {
+			t.Errorf("Message(%q) didn't fail", h)
+		}
+	}
+}
+
+// smtpServer is a stand-in mail server that offers STARTTLS and AUTH PLAIN.
+type smtpServer struct {
+	ln  net.Listener
+	tls *tls.Config
+
+	mu    sync.Mutex
+	mails []smtpMail
+}
+
+type smtpMail struct {
+	from string
+	to   []string
+	auth string
+	tls  bool
+	data []byte
+}
+
+func newSMTPServer(t *testing.T, config *tls.Config) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{ln: ln, tls: config}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	var m smtpMail
+	tp := newTextConn(conn)
+	tp.reply("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		arg := strings.TrimSpace(strings.TrimPrefix(line, line[:len(verb)]))
+		switch verb {
+		case "EHLO":
+			if m.tls || s.tls == nil {
+				tp.reply("250-localhost\r\n250 AUTH PLAIN")
+			} else {
+				tp.reply("250-localhost\r\n250-STARTTLS\r\n250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			tp.reply("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, m.tls = tlsConn, true
+			tp = newTextConn(conn)
+		case "AUTH":
+			auth, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			m.auth = string(auth)
+			tp.reply("235 ok")
+		case "MAIL":
+			m.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			tp.reply("250 ok")
+		case "RCPT":
+			m.to = append(m.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			tp.reply("250 ok")
+		case "DATA":
+			tp.reply("354 go ahead")
+			if m.data, err = tp.ReadDotBytes(); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.mails = append(s.mails, m)
+			s.mu.Unlock()
+			tp.reply("250 ok")
+		case "QUIT":
+			tp.reply("221 bye")
+			return
+		default:
+			tp.reply("502 unsupported")
+		}
+	}
+}
+
+type textConn struct{ *textproto.Conn }
+
+func newTextConn(conn net.Conn) textConn {
+	return textConn{textproto.NewConn(conn)}
+}
+
+func (c textConn) reply(line string) {
+	c.PrintfLine("%s", line)
+}
+
+func (s *smtpServer) received() []smtpMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mails
+}
+
+// tlsConfigs borrows the test certificate of httptest for a server and a
+// client that trusts it.
+func tlsConfigs(t *testing.T) (server, client *tls.Config) {
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(ts.Close)
+	client = ts.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	client.ServerName = "example.com"
+	return ts.TLS, client
+}
+
+func TestSend(t *testing.T) {
+	serverTLS, clientTLS := tlsConfigs(t)
+	s := newSMTPServer(t, serverTLS)
+	d, err := digest.Gather(context.Background(), newFake(t), since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := []string{"a@example.com", "b@example.com"}
+	msg, err := digest.Message(d, digest.Header{From: "bot@example.com", To: to})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = digest.SMTP{
+		Addr:      s.ln.Addr().String(),
+		Username:  "bot",
+		Password:  "hunter2",
+		StartTLS:  true,
+		TLSConfig: clientTLS,
+	}.Send(ctx, "bot@example.com", to, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mails := s.received()
+	if len(mails) != 1 {
+		t.Fatalf("server got %d mails", len(mails))
+	}
+	m := mails[0]
+	if !m.tls || m.auth != "\x00bot\x00hunter2" || m.from != "bot@example.com" || fmt.Sprint(m.to) != fmt.Sprint(to) {
+		t.Errorf("mail = %+v", m)
+	}
+	if _, bodies := parts(t, m.data); !strings.Contains(bodies["text/plain"], "@2 Exam room?") {
+		t.Errorf("mail text = %q", bodies["text/plain"])
+	}
+
+	// Servers without STARTTLS are refused when it's required.
+	plain := newSMTPServer(t, nil)
+	if err := (digest.SMTP{Addr: plain.ln.Addr().String(), StartTLS: true}).Send(ctx, "bot@example.com", to, msg); err == nil {
+		t.Errorf("expected an error without STARTTLS")
+	}
+	if err := (digest.SMTP{Addr: plain.ln.Addr().String()}).Send(ctx, "bot@example.com", to, msg); err != nil {
+		t.Fatal(err)
+	}
+	if mails := plain.received(); len(mails) != 1 || mails[0].tls {
+		t.Errorf("plain server got %+v", mails)
+	}
+}
+
+func TestWriteEML(t *testing.T) {
+	dir := t.TempDir()
+	when := time.Date(2016, 9, 14, 8, 30, 0, 0, time.UTC)
+	file, err := digest.WriteEML(dir, when, []byte("Subject: hi\r\n\r\nhi\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(file, "digest-20160914T083000Z.eml") {
+		t.Errorf("file = %q", file)
+	}
+	if buf, err := ioutil.ReadFile(file); err != nil || string(buf) != "Subject: hi\r\n\r\nhi\r\n" {
+		t.Errorf("file has %q, %v", buf, err)
+	}
+	if fi, err := os.Stat(file); err != nil {
+		t.Error(err)
+	} else if fi.Mode().Perm() != 0600 {
+		t.Errorf("file mode = %v; expected it private", fi.Mode())
+	}
+}
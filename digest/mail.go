@@ -0,0 +1,184 @@
+package digest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Header is who a digest email is from and to. Addresses may have a display
+// name, as in "Piazza Digest <bot@example.com>".
+type Header struct {
+	From string
+	To   []string
+	// Subject defaults to one counting the posts.
+	Subject string
+}
+
+// Subject returns the default subject of an email of d.
+func (d Digest) Subject() string {
+	posts := "posts"
+	if d.Len() == 1 {
+		posts = "post"
+	}
+	classes := "classes"
+	if len(d.Classes) == 1 {
+		classes = "class"
+	}
+	return fmt.Sprintf("Piazza digest: %d %s in %d %s", d.Len(), posts, len(d.Classes), classes)
+}
+
+var funcs = map[string]interface{}{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return "the beginning"
+		}
+		return t.Local().Format("Mon Jan 2 15:04")
+	},
+	"indent": func(s string) string {
+		return strings.Replace(s, "\n", "\n      ", -1)
+	},
+}
+
+var textTmpl = template.Must(template.New("text").Funcs(funcs).Parse(
+	`{{.Subject}} since {{date .Since}}
+{{range .Classes}}
+{{.Name}}
+{{range .Folders}}
+  {{.Name}}
+{{range .Posts}}    @{{.Nr}} {{.Subject}}{{if .New}} [new]{{end}}{{if .Unanswered}} [unanswered]{{end}}
+{{if .Snippet}}      {{indent .Snippet}}
+{{end}}      {{.URL}}
+{{end}}{{end}}{{end}}`))
+
+var htmlTmpl = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body style="font-family: sans-serif; max-width: 40em">
+<p style="color: #666">{{.Subject}} since {{date .Since}}</p>
+{{range .Classes}}<h2 style="margin-bottom: 0.2em">{{.Name}}</h2>
+{{range .Folders}}<h3 style="margin: 0.8em 0 0.2em; color: #3e7aab">{{.Name}}</h3>
+<ul style="padding-left: 1.2em">
+{{range .Posts}}<li style="margin-bottom: 0.5em"><a href="{{.URL}}">@{{.Nr}} {{.Subject}}</a>{{if .New}} <b>new</b>{{end}}{{if .Unanswered}} <i>unanswered</i>{{end}}{{if .Snippet}}<br><span style="color: #666">{{.Snippet}}</span>{{end}}</li>
+{{end}}</ul>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// Text renders d as plain text.
+func (d Digest) Text() (string, error) {
+	var buf bytes.Buffer
+	err := textTmpl.Execute(&buf, d)
+	return buf.String(), err
+}
+
+// HTML renders d as an HTML page.
+func (d Digest) HTML() (string, error) {
+	var buf bytes.Buffer
+	err := htmlTmpl.Execute(&buf, d)
+	return buf.String(), err
+}
+
+// formatAddresses parses addrs and formats them for a header, encoding
+// display names as needed. Empty ones are skipped.
+func formatAddresses(addrs ...string) (string, error) {
+	var formatted []string
+	for _, addr := range addrs {
+		if len(addr) == 0 {
+			continue
+		}
+		a, err := mail.ParseAddress(addr)
+		if err != nil {
+			return "", errors.Wrapf(err, "address %q", addr)
+		}
+		formatted = append(formatted, a.String())
+	}
+	return strings.Join(formatted, ", "), nil
+}
+
+// Message returns an email of d with both plain text and HTML versions. It
+// fails if an address in h isn't valid; From and To are left out if empty.
+func Message(d Digest, h Header) ([]byte, error) {
+	from, err := formatAddresses(h.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := formatAddresses(h.To...)
+	if err != nil {
+		return nil, err
+	}
+	text, err := d.Text()
+	if err != nil {
+		return nil, err
+	}
+	html, err := d.HTML()
+	if err != nil {
+		return nil, err
+	}
+	subject := h.Subject
+	if len(subject) == 0 {
+		subject = d.Subject()
+	}
+	date := d.Until
+	if date.IsZero() {
+		date = time.Now()
+	}
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, header := range [][2]string{
+		{"From", from},
+		{"To", to},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s.%d@piazza-digest>", hex.EncodeToString(id), date.Unix())},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + mw.Boundary()},
+	} {
+		if len(header[1]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "%s: %s\r\n", header[0], header[1])
+	}
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ typ, body string }{
+		{"text/plain", text},
+		{"text/html", html},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.typ + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,107 @@
+package digest
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SMTP is a mail server to send digests through.
+type SMTP struct {
+	// Addr is the server's host:port.
+	Addr string
+	// Username and Password, if set, are sent with AUTH PLAIN, which is only
+	// done over TLS or to localhost.
+	Username, Password string
+	// StartTLS makes upgrading the connection with STARTTLS required. It's
+	// done whenever the server offers it either way.
+	StartTLS bool
+	// TLSConfig defaults to verifying the server's certificate for its
+	// host.
+	TLSConfig *tls.Config
+}
+
+// Send sends msg from from to the addresses to.
+func (s SMTP) Send(ctx context.Context, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Closing the connection aborts the exchange if ctx is done first.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		config := s.TLSConfig
+		if config == nil {
+			config = &tls.Config{ServerName: host}
+		}
+		if err := c.StartTLS(config); err != nil {
+			return errors.Wrap(err, "STARTTLS")
+		}
+	} else if s.StartTLS {
+		return errors.Errorf("%s doesn't support STARTTLS", s.Addr)
+	}
+	if len(s.Username) > 0 {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return errors.Wrap(err, "AUTH")
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return errors.Wrapf(err, "RCPT TO %s", addr)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// WriteEML writes msg to a .eml file in dir named after when, and returns its
+// path. Only the user can read the file, as it may have private posts in it.
+func WriteEML(dir string, when time.Time, msg []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, "digest-"+when.UTC().Format("20060102T150405Z")+".eml")
+	return file, ioutil.WriteFile(file, msg, 0600)
+}
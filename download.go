@@ -0,0 +1,50 @@
+package piazza
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Download fetches a file such as an attachment or a resource and copies it to
+// w. Relative URLs like "/redirect/s3?..." are resolved against the site the
+// client talks to, and the request carries the login cookies so files that
+// need a login can be fetched.
+func (c *Client) Download(ctx context.Context, rawurl string, w io.Writer) error {
+	u, err := c.base.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if len(c.ua) > 0 {
+		req.Header.Set("User-Agent", c.ua)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.Request.URL.Path == c.loginPath() && u.Path != c.loginPath() {
+		return errors.Wrapf(ErrNotLoggedIn, "downloading %q", rawurl)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return errors.Wrapf(ErrNotFound, "downloading %q", rawurl)
+	case http.StatusForbidden:
+		return errors.Wrapf(ErrPermissionDenied, "downloading %q", rawurl)
+	case http.StatusTooManyRequests:
+		return errors.Wrapf(ErrRateLimited, "downloading %q", rawurl)
+	default:
+		return errors.Errorf("downloading %q: StatusCode = %d", rawurl, resp.StatusCode)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return errors.Wrapf(err, "downloading %q", rawurl)
+}
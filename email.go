@@ -0,0 +1,127 @@
+package piazza
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// EmailFrequency is how often Piazza emails about a kind of activity.
+type EmailFrequency string
+
+// The frequencies Piazza offers.
+const (
+	EmailRealTime EmailFrequency = "real-time"
+	EmailDigest   EmailFrequency = "digest"
+	EmailNone     EmailFrequency = "no-emails"
+)
+
+// ParseEmailFrequency returns the frequency s names. Besides the values
+// themselves, "realtime", "off" and "none" are accepted.
+func ParseEmailFrequency(s string) (EmailFrequency, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case string(EmailRealTime), "realtime":
+		return EmailRealTime, nil
+	case string(EmailDigest):
+		return EmailDigest, nil
+	case string(EmailNone), "off", "none":
+		return EmailNone, nil
+	}
+	return "", errors.Errorf("unknown email frequency %q; expected %s, %s or %s", s, EmailRealTime, EmailDigest, EmailNone)
+}
+
+func (f EmailFrequency) valid() bool {
+	return f == EmailRealTime || f == EmailDigest || f == EmailNone
+}
+
+// careerPrefs is the EmailPrefs entry that isn't a class.
+const careerPrefs = "career"
+
+// EmailPrefUpdate is a change to the email preferences of a class. Zero fields
+// are left as they are.
+type EmailPrefUpdate struct {
+	New     EmailFrequency
+	Updates EmailFrequency
+	// NoEvents, if set, turns emails about class events off or on.
+	NoEvents *bool
+	// AutoFollow, if set, turns following every new post off or on.
+	AutoFollow *bool
+	// Throttle, if set, replaces the class's email throttle. It can't be
+	// negative.
+	Throttle *int
+}
+
+// EmailPrefs returns the user's email preferences by class ID.
+func (c *Client) EmailPrefs(ctx context.Context) (EmailPrefs, error) {
+	status, err := c.UserStatusContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prefs := status.Result.Config.EmailPrefs
+	delete(prefs, careerPrefs)
+	if prefs == nil {
+		prefs = EmailPrefs{}
+	}
+	return prefs, nil
+}
+
+// With returns a copy of p with u applied to the class nid, or to every class
+// if nid is empty.
+func (p EmailPrefs) With(nid string, u EmailPrefUpdate) (EmailPrefs, error) {
+	for _, f := range []EmailFrequency{u.New, u.Updates} {
+		if len(f) > 0 && !f.valid() {
+			return nil, errors.Errorf("unknown email frequency %q", f)
+		}
+	}
+	if u.Throttle != nil && *u.Throttle < 0 {
+		return nil, errors.Errorf("email throttle %d is negative", *u.Throttle)
+	}
+	if _, ok := p[nid]; len(nid) > 0 && !ok {
+		return nil, errors.Errorf("no email preferences for class %s", nid)
+	}
+	next := EmailPrefs{}
+	for id, pref := range p {
+		if len(nid) == 0 || id == nid {
+			if len(u.New) > 0 {
+				pref.New = u.New
+			}
+			if len(u.Updates) > 0 {
+				pref.Updates = u.Updates
+			}
+			if u.NoEvents != nil {
+				pref.NoEvents = *u.NoEvents
+			}
+			if u.AutoFollow != nil {
+				pref.AutoFollow = *u.AutoFollow
+			}
+			if u.Throttle != nil {
+				pref.Throttle = *u.Throttle
+			}
+		}
+		next[id] = pref
+	}
+	return next, nil
+}
+
+type updateEmailsReq struct {
+	EmailPrefs EmailPrefs `json:"email_prefs"`
+}
+
+// SetEmailPrefs applies u to the email preferences of the class nid, or of
+// every class if nid is empty, and returns the preferences it set. Settings u
+// leaves unset are kept.
+func (c *Client) SetEmailPrefs(ctx context.Context, nid string, u EmailPrefUpdate) (EmailPrefs, error) {
+	prefs, err := c.EmailPrefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	next, err := prefs.With(nid, u)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.MakeAPIReqContext(ctx, "user.update", updateEmailsReq{next}, nil); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
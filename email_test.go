@@ -0,0 +1,97 @@
+package piazza_test
+
+import (
+	"context"
+	"testing"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/piazzatest"
+)
+
+func TestSetEmailPrefs(t *testing.T) {
+	s := piazzatest.NewServer()
+	t.Cleanup(s.Close)
+	s.AddUser(piazzatest.User{
+		ID:       "u1",
+		Email:    "student@example.com",
+		Password: "hunter2",
+		EmailPrefs: piazza.EmailPrefs{
+			"n1":     {New: piazza.EmailRealTime, Updates: piazza.EmailRealTime, Throttle: 3, AutoFollow: "all"},
+			"n2":     {New: piazza.EmailDigest, Updates: piazza.EmailDigest},
+			"career": {New: piazza.EmailRealTime},
+		},
+	})
+	s.AddNetwork(piazza.Network{ID: "n1"}, "u1")
+	s.AddNetwork(piazza.Network{ID: "n2"}, "u1")
+	c, err := piazza.MakeClientWithOptions("student@example.com", "hunter2", s.ClientOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	prefs, err := c.EmailPrefs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := prefs["career"]; ok || len(prefs) != 2 || prefs["n1"].New != piazza.EmailRealTime {
+		t.Errorf("EmailPrefs() = %+v", prefs)
+	}
+
+	noEvents := true
+	if _, err := c.SetEmailPrefs(ctx, "n1", piazza.EmailPrefUpdate{Updates: piazza.EmailNone, NoEvents: &noEvents}); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := s.User("u1")
+	n1, n2 := u.EmailPrefs["n1"], u.EmailPrefs["n2"]
+	if n1.New != piazza.EmailRealTime || n1.Updates != piazza.EmailNone || !n1.NoEvents || n1.Throttle != 3 || n1.AutoFollow != "all" {
+		t.Errorf("n1 prefs = %+v", n1)
+	}
+	if n2.Updates != piazza.EmailDigest {
+		t.Errorf("n2 prefs changed: %+v", n2)
+	}
+
+	if _, err := c.SetEmailPrefs(ctx, "", piazza.EmailPrefUpdate{New: piazza.EmailDigest}); err != nil {
+		t.Fatal(err)
+	}
+	u, _ = s.User("u1")
+	if u.EmailPrefs["n1"].New != piazza.EmailDigest || u.EmailPrefs["n2"].New != piazza.EmailDigest || u.EmailPrefs["career"].New != piazza.EmailRealTime {
+		t.Errorf("prefs = %+v", u.EmailPrefs)
+	}
+
+	follow, throttle := false, 0
+	if _, err := c.SetEmailPrefs(ctx, "n1", piazza.EmailPrefUpdate{AutoFollow: &follow, Throttle: &throttle}); err != nil {
+		t.Fatal(err)
+	}
+	u, _ = s.User("u1")
+	if n1 := u.EmailPrefs["n1"]; n1.AutoFollow != false || n1.Throttle != 0 || n1.New != piazza.EmailDigest || !n1.NoEvents {
+		t.Errorf("n1 prefs = %+v", n1)
+	}
+
+	throttle = -1
+	if _, err := c.SetEmailPrefs(ctx, "n1", piazza.EmailPrefUpdate{Throttle: &throttle}); err == nil {
+		t.Errorf("expected an error for a negative throttle")
+	}
+	if _, err := c.SetEmailPrefs(ctx, "n3", piazza.EmailPrefUpdate{New: piazza.EmailDigest}); err == nil {
+		t.Errorf("expected an error for a class without preferences")
+	}
+	if _, err := c.SetEmailPrefs(ctx, "n1", piazza.EmailPrefUpdate{New: "weekly"}); err == nil {
+		t.Errorf("expected an error for an unknown frequency")
+	}
+}
+
+func TestParseEmailFrequency(t *testing.T) {
+	for in, want := range map[string]piazza.EmailFrequency{
+		"real-time": piazza.EmailRealTime,
+		"realtime":  piazza.EmailRealTime,
+		"Digest":    piazza.EmailDigest,
+		"off":       piazza.EmailNone,
+		"no-emails": piazza.EmailNone,
+	} {
+		if got, err := piazza.ParseEmailFrequency(in); err != nil || got != want {
+			t.Errorf("ParseEmailFrequency(%q) = %q, %v; expected %q", in, got, err, want)
+		}
+	}
+	if _, err := piazza.ParseEmailFrequency("weekly"); err == nil {
+		t.Errorf("expected an error for %q", "weekly")
+	}
+}
@@ -0,0 +1,105 @@
+package piazza
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Kinds of API errors. An *APIError wraps one of these when its message is
+// recognized, so callers can check for them with errors.Is.
+var (
+	ErrNotLoggedIn      = errors.New("not logged in")
+	ErrNotFound         = errors.New("not found")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrRateLimited      = errors.New("rate limited")
+	ErrUnknownMethod    = errors.New("unknown method")
+)
+
+// APIError is returned when Piazza reports an error for an API call.
+type APIError struct {
+	// Method is the API method that failed, e.g. "content.get".
+	Method string
+	// Message is the error text Piazza sent.
+	Message string
+	// Kind is one of the Err* values, or nil if the message wasn't recognized.
+	Kind error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("method %q: %s", e.Method, e.Message)
+}
+
+// Unwrap returns the kind of error so errors.Is works on it.
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
+
+// errorKinds maps fragments of Piazza's error messages to the kind of error.
+// They are checked in order, so "Method not found" is an unknown method rather
+// than missing content. The not-logged-in ones are whole phrases, since
+// renewing the session on an unrelated error that merely mentions logins
+// would be wasted.
+var errorKinds = []struct {
+	kind      error
+	fragments []string
+}{
+	{ErrRateLimited, []string{"too many", "rate limit", "slow down", "too fast"}},
+	{ErrUnknownMethod, []string{"method not found", "unknown method", "no such method"}},
+	{ErrNotLoggedIn, []string{"not logged in", "please log in", "please login", "must be logged in", "must log in"}},
+	{ErrPermissionDenied, []string{"permission", "not allowed", "not authorized", "unauthorized", "access denied"}},
+	{ErrNotFound, []string{"not found", "could not find", "cannot be found", "does not exist", "doesn't exist", "no such"}},
+}
+
+// isKind reports whether err is an *APIError of the given kind.
+func isKind(err error, kind error) bool {
+	apiErr, ok := errors.Cause(err).(*APIError)
+	return ok && apiErr.Kind == kind
+}
+
+func errorKind(msg string) error {
+	msg = strings.ToLower(msg)
+	for _, k := range errorKinds {
+		for _, f := range k.fragments {
+			if strings.Contains(msg, f) {
+				return k.kind
+			}
+		}
+	}
+	return nil
+}
+
+// apiEnvelope is the wrapper every API response comes in.
+type apiEnvelope struct {
+	Error interface{} `json:"error"`
+}
+
+// checkEnvelope returns an *APIError if body reports one. Bodies that aren't
+// an envelope are left for the caller to complain about.
+func checkEnvelope(method string, body []byte) error {
+	var env apiEnvelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return nil
+	}
+	var msg string
+	switch e := env.Error.(type) {
+	case nil:
+		return nil
+	case bool:
+		if !e {
+			return nil
+		}
+		msg = "unknown error"
+	case string:
+		if len(e) == 0 {
+			return nil
+		}
+		msg = e
+	default:
+		buf, _ := json.Marshal(e)
+		msg = string(buf)
+	}
+	return &APIError{Method: method, Message: msg, Kind: errorKind(msg)}
+}
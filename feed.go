@@ -0,0 +1,222 @@
+package piazza
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// FeedSort is the order a feed is listed in.
+type FeedSort string
+
+// The orders a feed can be listed in.
+const (
+	SortUpdated FeedSort = "updated"
+	SortCreated FeedSort = "created"
+	SortUnread  FeedSort = "unread"
+)
+
+// FeedFilter restricts a feed to some of its posts.
+type FeedFilter string
+
+// The filters Piazza supports. The empty filter lists every post.
+const (
+	FilterUnread     FeedFilter = "unread"
+	FilterUnresolved FeedFilter = "unresolved"
+	FilterFollowing  FeedFilter = "following"
+	FilterInstructor FeedFilter = "instructor"
+)
+
+// DefaultFeedPageSize is the page size used when FeedOptions.PageSize is
+// unset.
+const DefaultFeedPageSize = 100
+
+// FeedOptions controls how FeedPages lists a feed.
+type FeedOptions struct {
+	// PageSize is how many items are requested at a time.
+	PageSize int
+	// Sort defaults to SortUpdated. Posts move to the front of that order as
+	// they change, so a walk that must see every post once should use
+	// SortCreated, which is stable.
+	Sort FeedSort
+	// Folder, if set, only lists posts in that folder.
+	Folder string
+	Filter FeedFilter
+}
+
+// params returns the request for a page. Unfiltered feeds come from
+// "network.get_my_feed" and filtered ones from "network.filter_feed".
+func (o FeedOptions) params(nid string, offset int) (string, interface{}) {
+	if len(o.Folder) == 0 && len(o.Filter) == 0 {
+		return "network.get_my_feed", feedReq{Nid: nid, Limit: o.PageSize, Offset: offset, Sort: string(o.Sort)}
+	}
+	params := map[string]interface{}{
+		"nid":    nid,
+		"limit":  o.PageSize,
+		"offset": offset,
+		"sort":   string(o.Sort),
+	}
+	if len(o.Folder) > 0 {
+		params["folder"] = 1
+		params["filter_folder"] = o.Folder
+	}
+	if len(o.Filter) > 0 {
+		params[string(o.Filter)] = 1
+	}
+	return "network.filter_feed", params
+}
+
+// FeedIterator streams the items of a feed a page at a time:
+//
+//	it := c.FeedPages(ctx, nid, piazza.FeedOptions{})
+//	for it.Next() {
+//		item := it.Item()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type FeedIterator struct {
+	c    *Client
+	ctx  context.Context
+	nid  string
+	opts FeedOptions
+
+	resp   FeedResponse
+	page   []FeedItem
+	offset int
+	done   bool
+	seen   map[string]bool
+	item   FeedItem
+	err    error
+}
+
+// FeedPages returns an iterator over the feed of class nid.
+func (c *Client) FeedPages(ctx context.Context, nid string, opts FeedOptions) *FeedIterator {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultFeedPageSize
+	}
+	if len(opts.Sort) == 0 {
+		opts.Sort = SortUpdated
+	}
+	return &FeedIterator{
+		c:    c,
+		ctx:  ctx,
+		nid:  nid,
+		opts: opts,
+		seen: map[string]bool{},
+	}
+}
+
+// Next advances to the next item, fetching another page if needed. It returns
+// false at the end of the feed or on error.
+func (it *FeedIterator) Next() bool {
+	for {
+		for len(it.page) > 0 {
+			it.item, it.page = it.page[0], it.page[1:]
+			// Posts that change while paging can move onto a later page.
+			if it.seen[it.item.ID] {
+				continue
+			}
+			it.seen[it.item.ID] = true
+			return true
+		}
+		if it.done || it.err != nil {
+			return false
+		}
+		it.fetch()
+	}
+}
+
+func (it *FeedIterator) fetch() {
+	method, params := it.opts.params(it.nid, it.offset)
+	var resp FeedResponse
+	if err := it.c.MakeAPIReqContext(it.ctx, method, params, &resp); err != nil {
+		it.err = err
+		return
+	}
+	it.resp = resp
+	it.page = resp.Result.Feed
+	it.offset += len(it.page)
+	it.done = !resp.Result.More || len(it.page) == 0
+}
+
+// Item returns the current item.
+func (it *FeedIterator) Item() FeedItem {
+	return it.item
+}
+
+// Err returns the error that stopped iteration, if any.
+func (it *FeedIterator) Err() error {
+	return it.err
+}
+
+// Response returns the last page fetched, which carries the feed's metadata.
+func (it *FeedIterator) Response() FeedResponse {
+	return it.resp
+}
+
+// FeedLogEntry is an event in a feed item's history.
+type FeedLogEntry struct {
+	// Type is what happened, such as "create", "update", "followup" or
+	// "i_answer".
+	Type string `json:"n"`
+	When Time   `json:"t"`
+	// UID is who did it. It's empty for anonymous actions.
+	UID string `json:"u"`
+}
+
+// IsUnanswered reports whether a post is waiting on someone: a question with
+// no answer yet, private ones to instructors included, or any post with
+// unresolved followups.
+func (f FeedItem) IsUnanswered() bool {
+	if f.NoAnswerFollowup > 0 {
+		return true
+	}
+	return f.Type == string(PostQuestion) && f.NoAnswer > 0
+}
+
+// IsInstructorPost reports whether an instructor wrote the post, which Piazza
+// marks with an "instructor-note" or "instructor-question" tag.
+func (f FeedItem) IsInstructorPost() bool {
+	for _, tag := range f.Tags {
+		if strings.HasPrefix(tag, "instructor-") {
+			return true
+		}
+	}
+	return false
+}
+
+// Pinned reports whether the post is pinned to the top of the feed.
+func (f FeedItem) Pinned() bool {
+	return f.Pin > 0 || f.BucketName == "Pinned"
+}
+
+// LastActivity returns when the post or any reply to it last changed.
+func (f FeedItem) LastActivity() Time {
+	last := f.Updated
+	if f.Modified.After(last.Time) {
+		last = f.Modified
+	}
+	for _, entry := range f.Log {
+		if entry.When.After(last.Time) {
+			last = entry.When
+		}
+	}
+	return last
+}
+
+// URL returns the link to the post in class nid.
+func (f FeedItem) URL(nid string) string {
+	return postURL(DefaultBaseURL, nid, f.Nr)
+}
+
+func postURL(base, nid string, nr int) string {
+	return fmt.Sprintf("%s/class/%s?cid=%d", base, nid, nr)
+}
+
+// PostURL returns the link to post number nr in class nid on the site the
+// client talks to.
+func (c *Client) PostURL(nid string, nr int) string {
+	return postURL(c.BaseURL(), nid, nr)
+}
@@ -0,0 +1,114 @@
+package piazza_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	piazza "github.com/d4l3k/piazza-api"
+)
+
+func TestFeedPages(t *testing.T) {
+	s, c := newFake(t)
+	ctx := context.Background()
+	for i := 0; i < 25; i++ {
+		s.AddPost("n1", mustPost(t, fmt.Sprintf(`{
+			"type": "note",
+			"created": "2017-01-%02dT00:00:00Z",
+			"folders": ["logistics"]
+		}`, i+1)))
+	}
+
+	it := c.FeedPages(ctx, "n1", piazza.FeedOptions{PageSize: 10})
+	seen := map[string]bool{}
+	for it.Next() {
+		seen[it.Item().ID] = true
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 27 {
+		t.Errorf("iterated over %d items; not 27", len(seen))
+	}
+
+	feed, err := c.Feed("n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Result.Feed) != 27 || feed.Result.More {
+		t.Errorf("c.Feed() returned %d items, more = %t", len(feed.Result.Feed), feed.Result.More)
+	}
+
+	count := func(opts piazza.FeedOptions) int {
+		it := c.FeedPages(ctx, "n1", opts)
+		n := 0
+		for it.Next() {
+			n++
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count(piazza.FeedOptions{Folder: "hw1"}); n != 1 {
+		t.Errorf("%d items in hw1; not 1", n)
+	}
+	if n := count(piazza.FeedOptions{Folder: "logistics", PageSize: 7, Sort: piazza.SortCreated}); n != 25 {
+		t.Errorf("%d items in logistics; not 25", n)
+	}
+	// Only the question has no answer.
+	if n := count(piazza.FeedOptions{Filter: piazza.FilterUnresolved}); n != 1 {
+		t.Errorf("%d unresolved items; not 1", n)
+	}
+}
+
+func TestFeedItemHelpers(t *testing.T) {
+	s, student := newFake(t)
+	instructor := addModeratedClass(t, s)
+	ctx := context.Background()
+
+	if _, err := instructor.CreatePost(ctx, "n2", piazza.NewPost{Type: piazza.PostNote, Subject: "Welcome"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := instructor.AnswerAsInstructor(ctx, "n2", "q2", "Because."); err != nil {
+		t.Fatal(err)
+	}
+	if err := instructor.Pin(ctx, "n2", "q2"); err != nil {
+		t.Fatal(err)
+	}
+
+	feed, err := student.FeedContext(ctx, "n2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := map[int]piazza.FeedItem{}
+	for _, item := range feed.Result.Feed {
+		items[item.Nr] = item
+	}
+	q1, q2, note := items[1], items[2], items[3]
+	if !q1.IsUnanswered() || q2.IsUnanswered() || note.IsUnanswered() {
+		t.Errorf("IsUnanswered() = %t, %t, %t; expected only q1", q1.IsUnanswered(), q2.IsUnanswered(), note.IsUnanswered())
+	}
+	private := piazza.FeedItem{Type: string(piazza.PostQuestion), Status: "private", NoAnswer: 1}
+	if !private.IsUnanswered() {
+		t.Errorf("IsUnanswered() of a private question = false; expected true")
+	}
+	if q1.IsInstructorPost() || !note.IsInstructorPost() {
+		t.Errorf("IsInstructorPost() = %t, %t; expected only the note", q1.IsInstructorPost(), note.IsInstructorPost())
+	}
+	if q1.Pinned() || !q2.Pinned() {
+		t.Errorf("Pinned() = %t, %t; expected only q2", q1.Pinned(), q2.Pinned())
+	}
+	if len(q2.Log) == 0 || q2.Log[len(q2.Log)-1].Type != "i_answer" {
+		t.Errorf("q2.Log = %+v; expected the instructor answer last", q2.Log)
+	}
+	if q2.LastActivity().IsZero() || q2.LastActivity().Before(q2.Updated.Time) {
+		t.Errorf("q2.LastActivity() = %v", q2.LastActivity())
+	}
+	if got, want := q2.URL("n2"), piazza.DefaultBaseURL+"/class/n2?cid=2"; got != want {
+		t.Errorf("URL() = %q; not %q", got, want)
+	}
+	if got, want := student.PostURL("n2", 2), s.URL+"/class/n2?cid=2"; got != want {
+		t.Errorf("PostURL() = %q; not %q", got, want)
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/d4l3k/piazza-api
+
+go 1.25.0
+
+require (
+	github.com/PuerkitoBio/goquery v1.13.0
+	github.com/headzoo/surf v1.0.1
+	github.com/mattn/go-sqlite3 v1.14.52
+	github.com/pkg/errors v0.9.1
+	github.com/yuin/goldmark v1.8.6
+	golang.org/x/net v0.58.0
+	mvdan.cc/xurls v1.1.0
+)
+
+require github.com/andybalholm/cascadia v1.3.4 // indirect
@@ -0,0 +1,261 @@
+package piazza
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// checkPermission returns an error matching ErrPermissionDenied unless the
+// logged in user's role in the class has one of perms. It returns the class on
+// success.
+func (c *Client) checkPermission(ctx context.Context, nid string, perms ...Permission) (Network, error) {
+	status, err := c.UserStatusContext(ctx)
+	if err != nil {
+		return Network{}, err
+	}
+	return statusPermission(status, nid, perms...)
+}
+
+// statusPermission is checkPermission for an already fetched user status.
+func statusPermission(status UserStatus, nid string, perms ...Permission) (Network, error) {
+	n, err := status.network(nid)
+	if err != nil {
+		return Network{}, err
+	}
+	role := status.Result.Config.Roles[nid]
+	var names []string
+	for _, perm := range perms {
+		if n.Can(role, perm) {
+			return n, nil
+		}
+		names = append(names, string(perm))
+	}
+	return Network{}, errors.Wrapf(ErrPermissionDenied, "role %q in class %q lacks %s", role, nid, strings.Join(names, " or "))
+}
+
+type contentUpdateReq struct {
+	Nid       string   `json:"nid"`
+	Cid       string   `json:"cid"`
+	Type      string   `json:"type"`
+	Subject   string   `json:"subject"`
+	Content   string   `json:"content"`
+	Revision  int      `json:"revision"`
+	Anonymous string   `json:"anonymous"`
+	Folders   []string `json:"folders,omitempty"`
+}
+
+// update writes a new revision of post.
+func (c *Client) update(ctx context.Context, nid string, post Post, subject, content string, folders []string) (Post, error) {
+	anon := string(AnonymousNo)
+	if len(post.History) > 0 {
+		anon = post.History[0].Anon
+	}
+	req := contentUpdateReq{
+		Nid:       nid,
+		Cid:       post.ID,
+		Type:      post.Type,
+		Subject:   subject,
+		Content:   content,
+		Revision:  len(post.History),
+		Anonymous: anon,
+		Folders:   folders,
+	}
+	var resp contentGetResponse
+	if err := c.MakeAPIReqContext(ctx, "content.update", req, &resp); err != nil {
+		return Post{}, err
+	}
+	return resp.Result, nil
+}
+
+// editPermission returns the permission needed to edit a post of type typ.
+func editPermission(typ string) Permission {
+	switch ChildType(typ) {
+	case StudentAnswer:
+		return PermMemberAnswerEdit
+	case InstructorAnswer:
+		return PermExpertAnswerEdit
+	case Followup, Feedback:
+		return PermFollowupEdit
+	}
+	return PermQuestionEdit
+}
+
+// postAuthor returns the ID of the user who wrote post: the creator in its
+// change log, or for replies without one the author of the reply or of its
+// first revision. It's empty for anonymous posts.
+func postAuthor(post Post) string {
+	for _, entry := range post.ChangeLog {
+		if entry.Type == "create" {
+			return entry.UID
+		}
+	}
+	if len(post.UID) > 0 {
+		return post.UID
+	}
+	if n := len(post.History); n > 0 {
+		return post.History[n-1].UID
+	}
+	return ""
+}
+
+// EditPost replaces the subject and content of a post or a reply to one with a
+// new revision. It needs the question_edit permission, member_answer_edit or
+// expert_answer_edit for student and instructor answers, or followup_edit for
+// followups and feedback. Authors may always edit their own posts.
+func (c *Client) EditPost(ctx context.Context, nid, postID, subject, content string) (Post, error) {
+	post, err := c.ContentContext(ctx, nid, postID)
+	if err != nil {
+		return Post{}, err
+	}
+	status, err := c.UserStatusContext(ctx)
+	if err != nil {
+		return Post{}, err
+	}
+	if author := postAuthor(post); len(author) == 0 || author != status.Result.ID {
+		if _, err := statusPermission(status, nid, editPermission(post.Type)); err != nil {
+			return Post{}, err
+		}
+	}
+	return c.update(ctx, nid, post, subject, content, nil)
+}
+
+// MoveToFolders replaces the folders of a post. It needs the question_edit
+// permission.
+func (c *Client) MoveToFolders(ctx context.Context, nid, postID string, folders []string) (Post, error) {
+	n, err := c.checkPermission(ctx, nid, PermQuestionEdit)
+	if err != nil {
+		return Post{}, err
+	}
+	if err := validateFolders(n, folders); err != nil {
+		return Post{}, err
+	}
+	post, err := c.ContentContext(ctx, nid, postID)
+	if err != nil {
+		return Post{}, err
+	}
+	var subject, content string
+	if len(post.History) > 0 {
+		subject, content = post.History[0].Subject, post.History[0].Content
+	}
+	return c.update(ctx, nid, post, subject, content, folders)
+}
+
+type contentIDReq struct {
+	Nid string `json:"nid"`
+	Cid string `json:"cid"`
+}
+
+// DeletePost deletes a post. It needs the question_delete permission.
+func (c *Client) DeletePost(ctx context.Context, nid, postID string) error {
+	if _, err := c.checkPermission(ctx, nid, PermQuestionDelete); err != nil {
+		return err
+	}
+	return c.MakeAPIReqContext(ctx, "content.delete", contentIDReq{Nid: nid, Cid: postID}, nil)
+}
+
+type contentPinReq struct {
+	Nid string `json:"nid"`
+	Cid string `json:"cid"`
+	Pin bool   `json:"pin"`
+}
+
+func (c *Client) pin(ctx context.Context, nid, postID string, pin bool) error {
+	// There's no pin permission; it's limited to those who can delete posts,
+	// which in practice means instructors and TAs.
+	if _, err := c.checkPermission(ctx, nid, PermQuestionDelete); err != nil {
+		return err
+	}
+	return c.MakeAPIReqContext(ctx, "content.pin", contentPinReq{Nid: nid, Cid: postID, Pin: pin}, nil)
+}
+
+// Pin pins a post to the top of the class feed.
+func (c *Client) Pin(ctx context.Context, nid, postID string) error {
+	return c.pin(ctx, nid, postID, true)
+}
+
+// Unpin undoes Pin.
+func (c *Client) Unpin(ctx context.Context, nid, postID string) error {
+	return c.pin(ctx, nid, postID, false)
+}
+
+type contentResolveReq struct {
+	Nid      string `json:"nid"`
+	Cid      string `json:"cid"`
+	Resolved bool   `json:"resolved"`
+}
+
+func (c *Client) resolve(ctx context.Context, nid, cid string, resolved bool) error {
+	// No permission in the role config covers resolving, so Piazza is the only
+	// judge and its refusal is returned as is.
+	err := c.MakeAPIReqContext(ctx, "content.mark_resolved", contentResolveReq{Nid: nid, Cid: cid, Resolved: resolved}, nil)
+	if errors.Is(err, ErrPermissionDenied) {
+		return errors.Wrapf(err, "resolving %q in class %q", cid, nid)
+	}
+	return err
+}
+
+// MarkResolved marks a followup discussion as resolved. Given a post instead
+// of a followup, all of its followups are resolved. Piazza lets the post's
+// author and instructors do so; unlike the other moderation actions this isn't
+// checked before sending, so a refusal is whatever error Piazza returns.
+func (c *Client) MarkResolved(ctx context.Context, nid, cid string) error {
+	return c.resolve(ctx, nid, cid, true)
+}
+
+// MarkUnresolved undoes MarkResolved.
+func (c *Client) MarkUnresolved(ctx context.Context, nid, cid string) error {
+	return c.resolve(ctx, nid, cid, false)
+}
+
+// Endorse marks a post as good, which adds the user to its TagGood. If answer
+// is set, the post's answer of that type is endorsed instead. Endorsing the
+// instructor answer needs the expert_answer_endorse permission and anything
+// else member_answer_endorse.
+func (c *Client) Endorse(ctx context.Context, nid, postID string, answer ChildType) error {
+	post, err := c.ContentContext(ctx, nid, postID)
+	if err != nil {
+		return err
+	}
+	target := post
+	if len(answer) > 0 {
+		var ok bool
+		if target, ok = post.Child(answer); !ok {
+			return errors.Wrapf(ErrNotFound, "post %q has no %s", postID, answer)
+		}
+	}
+	perm := PermMemberAnswerEndorse
+	if answer == InstructorAnswer {
+		perm = PermExpertAnswerEndorse
+	}
+	if _, err := c.checkPermission(ctx, nid, perm); err != nil {
+		return err
+	}
+	return c.MakeAPIReqContext(ctx, "content.mark_good", contentIDReq{Nid: nid, Cid: target.ID}, nil)
+}
+
+type contentDuplicateReq struct {
+	Nid     string `json:"nid"`
+	CidDupe string `json:"cid_dupe"`
+	CidTo   string `json:"cid_to"`
+	Msg     string `json:"msg"`
+}
+
+// MarkDuplicate marks a post as a duplicate of the post numbered canonicalNr.
+// Like Pin it needs the question_delete permission.
+func (c *Client) MarkDuplicate(ctx context.Context, nid, postID string, canonicalNr int) error {
+	if _, err := c.checkPermission(ctx, nid, PermQuestionDelete); err != nil {
+		return err
+	}
+	canonical, err := c.ContentContext(ctx, nid, strconv.Itoa(canonicalNr))
+	if err != nil {
+		return err
+	}
+	if canonical.ID == postID {
+		return errors.Errorf("post %q can't be a duplicate of itself", postID)
+	}
+	req := contentDuplicateReq{Nid: nid, CidDupe: postID, CidTo: canonical.ID}
+	return c.MakeAPIReqContext(ctx, "content.duplicate", req, nil)
+}
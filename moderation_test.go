@@ -0,0 +1,205 @@
+package piazza_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/piazzatest"
+)
+
+// addModeratedClass adds a class where students can only edit and endorse, and
+// returns a client logged in as its instructor.
+func addModeratedClass(t *testing.T, s *piazzatest.Server) *piazza.Client {
+	var n piazza.Network
+	if err := json.Unmarshal([]byte(`{
+		"id": "n2",
+		"folders": ["hw1", "hw2"],
+		"config": {"roles": {
+			"student": {"question_edit": true, "new_followup": true, "member_answer_endorse": true},
+			"instructor": {"question_edit": true, "question_delete": true, "new_followup": true,
+				"member_answer_endorse": true, "expert_answer_endorse": true, "expert_answer_edit": true,
+				"followup_edit": true}
+		}}
+	}`), &n); err != nil {
+		t.Fatal(err)
+	}
+	s.AddUser(piazzatest.User{
+		ID:       "i1",
+		Email:    "prof@example.com",
+		Password: "hunter3",
+		Roles:    map[string]string{"n2": "instructor"},
+	})
+	s.AddNetwork(n, "u1", "i1")
+	s.AddPost("n2", mustPost(t, `{"id": "q1", "nr": 1, "type": "question", "history": [{"subject": "Q", "content": "Why?"}]}`))
+	s.AddPost("n2", mustPost(t, `{"id": "q2", "nr": 2, "type": "question", "history": [{"subject": "Q again", "content": "Why?"}]}`))
+
+	c, err := piazza.MakeClientWithOptions("prof@example.com", "hunter3", s.ClientOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestModerationPermissions(t *testing.T) {
+	s, student := newFake(t)
+	instructor := addModeratedClass(t, s)
+	ctx := context.Background()
+
+	// Students here may edit questions but not answers; instructors may edit
+	// both.
+	answer, err := instructor.AnswerAsInstructor(ctx, "n2", "q2", "Because.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := student.EditPost(ctx, "n2", answer.ID, "", "Not because."); !errors.Is(err, piazza.ErrPermissionDenied) {
+		t.Errorf("student EditPost() of an instructor answer = %v; not %v", err, piazza.ErrPermissionDenied)
+	}
+	if _, err := instructor.EditPost(ctx, "n2", answer.ID, "", "Because, really."); err != nil {
+		t.Fatal(err)
+	}
+	// Authors need no permission to edit their own posts.
+	own, err := student.AnswerAsStudent(ctx, "n2", "q2", "Because?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := student.EditPost(ctx, "n2", own.ID, "", "Because!"); err != nil {
+		t.Errorf("student EditPost() of their own answer = %v", err)
+	}
+
+	denied := map[string]error{
+		"DeletePost":    student.DeletePost(ctx, "n2", "q1"),
+		"Pin":           student.Pin(ctx, "n2", "q1"),
+		"MarkDuplicate": student.MarkDuplicate(ctx, "n2", "q2", 1),
+	}
+	for name, err := range denied {
+		if !errors.Is(err, piazza.ErrPermissionDenied) {
+			t.Errorf("student %s() = %v; not %v", name, err, piazza.ErrPermissionDenied)
+		}
+	}
+	if _, ok := s.Post("n2", "q1"); !ok {
+		t.Errorf("student deleted the post")
+	}
+
+	if _, err := student.EditPost(ctx, "n2", "q1", "Q (edited)", "Why not?"); err != nil {
+		t.Fatal(err)
+	}
+	if err := student.Endorse(ctx, "n2", "q1", ""); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := s.Post("n2", "q1")
+	if p.History[0].Subject != "Q (edited)" || len(p.TagGoodArr) != 1 || p.TagGoodArr[0] != "u1" {
+		t.Errorf("post after edit and endorse = %+v", p)
+	}
+}
+
+func TestModeration(t *testing.T) {
+	s, _ := newFake(t)
+	c := addModeratedClass(t, s)
+	ctx := context.Background()
+
+	if err := c.Pin(ctx, "n2", "q1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.MoveToFolders(ctx, "n2", "q1", []string{"hw2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.MoveToFolders(ctx, "n2", "q1", []string{"hw9"}); err == nil {
+		t.Errorf("MoveToFolders to a missing folder = nil; expected an error")
+	}
+	followup, err := c.AddFollowup(ctx, "n2", "q1", "Anyone?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MarkResolved(ctx, "n2", followup.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AnswerAsInstructor(ctx, "n2", "q1", "Because."); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Endorse(ctx, "n2", "q1", piazza.InstructorAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	p, _ := s.Post("n2", "q1")
+	if p.BucketName != "Pinned" {
+		t.Errorf("p.BucketName = %q; not pinned", p.BucketName)
+	}
+	if len(p.Folders) != 1 || p.Folders[0] != "hw2" {
+		t.Errorf("p.Folders = %v; not [hw2]", p.Folders)
+	}
+	if p.NoAnswerFollowup != 0 {
+		t.Errorf("p.NoAnswerFollowup = %d; not 0", p.NoAnswerFollowup)
+	}
+	if answer, _ := p.Child(piazza.InstructorAnswer); len(answer.TagGoodArr) != 1 {
+		t.Errorf("instructor answer endorsements = %v", answer.TagGoodArr)
+	}
+
+	if err := c.MarkDuplicate(ctx, "n2", "q2", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeletePost(ctx, "n2", "q2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Post("n2", "q2"); ok {
+		t.Errorf("post q2 wasn't deleted")
+	}
+}
+
+func TestMarkResolvedRefused(t *testing.T) {
+	s, student := newFake(t)
+	instructor := addModeratedClass(t, s)
+	ctx := context.Background()
+
+	followup, err := instructor.AddFollowup(ctx, "n2", "q1", "Anyone?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = student.MarkResolved(ctx, "n2", followup.ID)
+	var apiErr *piazza.APIError
+	if !errors.Is(err, piazza.ErrPermissionDenied) || !errors.As(err, &apiErr) {
+		t.Errorf("MarkResolved() of someone else's followup = %v; not %v", err, piazza.ErrPermissionDenied)
+	}
+
+	own, err := student.AddFollowup(ctx, "n2", "q1", "Me too")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := student.MarkResolved(ctx, "n2", own.ID); err != nil {
+		t.Errorf("MarkResolved() of the student's own followup = %v", err)
+	}
+}
+
+func TestMyPermissions(t *testing.T) {
+	s, student := newFake(t)
+	instructor := addModeratedClass(t, s)
+	ctx := context.Background()
+
+	perms, err := student.MyPermissions(ctx, "n2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !perms.QuestionEdit || !perms.Has(piazza.PermMemberAnswerEndorse) || perms.QuestionDelete || perms.Has(piazza.PermQuestionDelete) {
+		t.Errorf("student MyPermissions() = %+v", perms)
+	}
+	perms, err = instructor.MyPermissions(ctx, "n2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !perms.Has(piazza.PermQuestionDelete) || !perms.ExpertAnswerEndorse {
+		t.Errorf("instructor MyPermissions() = %+v", perms)
+	}
+	if _, err := student.MyPermissions(ctx, "nope"); !errors.Is(err, piazza.ErrNotFound) {
+		t.Errorf("MyPermissions() in an unknown class = %v; not %v", err, piazza.ErrNotFound)
+	}
+
+	n, err := student.Network(ctx, "n2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !n.Can("instructor", piazza.PermFollowupEdit) || n.Can("student", piazza.PermFollowupEdit) || n.Can("ghost", piazza.PermNewPost) {
+		t.Error("Network.Can() disagrees with the class config")
+	}
+}
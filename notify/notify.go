@@ -0,0 +1,396 @@
+// Package notify renders posts as chat messages and sends them to Slack and
+// Discord incoming webhooks:
+//
+//	msg := notify.Slack(network, post, notify.Options{})
+//	err := notify.Send(ctx, hookURL, msg)
+//
+// Messages show the post's number linked to it, its subject, the start of its
+// body as Markdown, its folders, whether an instructor has answered it and how
+// many times it and its answers were endorsed.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/render"
+	"github.com/pkg/errors"
+)
+
+// DefaultBodyLength is how much of a post's body messages show by default.
+const DefaultBodyLength = 500
+
+// The most of a body Slack and Discord accept in one block or embed.
+const (
+	slackTextLength          = 3000
+	discordTitleLength       = 256
+	discordDescriptionLength = 4096
+)
+
+// Options controls how messages are rendered and sent.
+type Options struct {
+	// BodyLength is how many characters of a post's body are shown before
+	// it's cut short. It defaults to DefaultBodyLength.
+	BodyLength int
+	// BaseURL is the site links point at. It defaults to
+	// piazza.DefaultBaseURL.
+	BaseURL string
+	// HTTPClient sends messages. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// summary is what a message says about a post.
+type summary struct {
+	nr      int
+	subject string
+	url     string
+	class   string
+	// body is Markdown.
+	body    string
+	folders []string
+	// instructorAnswer is whether an instructor has answered.
+	instructorAnswer bool
+	// endorsements is how many times the post was marked good, and
+	// answerEndorsements its answers.
+	endorsements       int
+	answerEndorsements int
+	created            piazza.Time
+}
+
+func summarize(n piazza.Network, p piazza.Post, opts Options) summary {
+	s := summary{
+		nr:           p.Nr,
+		subject:      p.Subject,
+		url:          postURL(opts.BaseURL, n, p.Nr),
+		class:        n.CourseNumber,
+		folders:      p.Folders,
+		endorsements: len(p.TagGood),
+		created:      p.Created,
+	}
+	if len(s.class) == 0 {
+		s.class = n.Name
+	}
+	var content string
+	if len(p.History) > 0 {
+		s.subject = p.History[0].Subject
+		content = p.History[0].Content
+		if s.created.IsZero() {
+			s.created = p.History[len(p.History)-1].Created
+		}
+	}
+	length := opts.BodyLength
+	if length <= 0 {
+		length = DefaultBodyLength
+	}
+	s.body = truncate(strings.TrimSpace(render.Options{BaseURL: opts.BaseURL}.ToMarkdown(content)), length)
+	for _, child := range p.Children {
+		switch piazza.ChildType(child.Type) {
+		case piazza.InstructorAnswer:
+			s.instructorAnswer = true
+		case piazza.StudentAnswer:
+		default:
+			continue
+		}
+		s.answerEndorsements += len(child.TagGood)
+	}
+	return s
+}
+
+// postURL links to post number nr. Classes with a public page, which is named
+// by the school, term and course number, link through it; the rest by their
+// ID.
+func postURL(base string, n piazza.Network, nr int) string {
+	if len(base) == 0 {
+		base = piazza.DefaultBaseURL
+	}
+	base = strings.TrimSuffix(base, "/")
+	if len(n.SchoolExt) == 0 || len(n.Term) == 0 || len(n.ShortNumber) == 0 {
+		return fmt.Sprintf("%s/class/%s?cid=%d", base, n.ID, nr)
+	}
+	term := strings.ToLower(strings.Replace(n.Term, " ", "", -1))
+	return fmt.Sprintf("%s/%s/%s/%s?cid=%d", base, n.SchoolExt, term, n.ShortNumber, nr)
+}
+
+// truncate cuts s down to at most max characters, at a space if there's one
+// near the end, and marks that it did. Code blocks cut open are closed.
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)[:max-1]
+	cut := len(runes)
+	for i := cut - 1; i > cut*3/4; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
+	}
+	s = strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + "…"
+	if strings.Count(s, "```")%2 == 1 {
+		s += "\n```"
+	}
+	return s
+}
+
+func (s summary) title() string {
+	return fmt.Sprintf("@%d %s", s.nr, s.subject)
+}
+
+// SlackText is a Block Kit text object.
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// SlackBlock is a Block Kit section or context block.
+type SlackBlock struct {
+	Type     string      `json:"type"`
+	Text     *SlackText  `json:"text,omitempty"`
+	Elements []SlackText `json:"elements,omitempty"`
+}
+
+// SlackMessage is the body of a Slack incoming webhook request.
+type SlackMessage struct {
+	// Text is shown in notifications.
+	Text   string       `json:"text"`
+	Blocks []SlackBlock `json:"blocks"`
+}
+
+// Slack renders p, a post in class n, as a Slack message.
+func Slack(n piazza.Network, p piazza.Post, opts Options) SlackMessage {
+	s := summarize(n, p, opts)
+	mrkdwn := func(text string) *SlackText {
+		return &SlackText{Type: "mrkdwn", Text: text}
+	}
+	msg := SlackMessage{
+		Text: s.title(),
+		Blocks: []SlackBlock{{
+			Type: "section",
+			Text: mrkdwn(fmt.Sprintf("*<%s|%s>*", s.url, slackEscape(strings.Replace(s.title(), "|", "/", -1)))),
+		}},
+	}
+	if len(s.body) > 0 {
+		body := truncate(slackMarkdown(s.body), slackTextLength)
+		msg.Blocks = append(msg.Blocks, SlackBlock{Type: "section", Text: mrkdwn(body)})
+	}
+
+	var context []string
+	if len(s.class) > 0 {
+		context = append(context, slackEscape(s.class))
+	}
+	if len(s.folders) > 0 {
+		context = append(context, tags(s.folders))
+	}
+	if s.instructorAnswer {
+		context = append(context, ":white_check_mark: Instructor answer")
+	}
+	if s.endorsements > 0 {
+		context = append(context, fmt.Sprintf(":+1: %s", plural(s.endorsements, "endorsement")))
+	}
+	if s.answerEndorsements > 0 {
+		context = append(context, fmt.Sprintf(":+1: %s of answers", plural(s.answerEndorsements, "endorsement")))
+	}
+	if len(context) > 0 {
+		msg.Blocks = append(msg.Blocks, SlackBlock{
+			Type:     "context",
+			Elements: []SlackText{*mrkdwn(strings.Join(context, "  ·  "))},
+		})
+	}
+	return msg
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackEscape(s string) string {
+	return slackEscaper.Replace(s)
+}
+
+var (
+	markdownCode    = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+	markdownBold    = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	markdownItalic  = regexp.MustCompile(`\*([^*\s](?:[^*]*[^*\s])?)\*`)
+	markdownLink    = regexp.MustCompile(`!?\[([^\]]*)\]\(([^)\s]+)\)`)
+	markdownHeading = regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
+)
+
+// held is where the characters render escapes go while the patterns above
+// run, in a private use plane so nothing in a post collides with them. Slack
+// has no escapes of its own, so they come out as they are.
+const held = 0xF0000
+
+// bold stands in for Slack's bold marker, so italics don't pick it up.
+const bold = "\x07"
+
+func hold(c rune) string {
+	return string(held + c)
+}
+
+func release(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= held && r < held+utf8.RuneSelf:
+			b.WriteString(slackEscape(string(r - held)))
+		case string(r) == bold:
+			b.WriteByte('*')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// slackMarkdown converts the Markdown Slack's mrkdwn doesn't understand: bold,
+// italics, links, headings and escapes. Code is left alone.
+func slackMarkdown(s string) string {
+	s = render.Unescape(s, hold)
+	var b strings.Builder
+	last := 0
+	for _, loc := range markdownCode.FindAllStringIndex(s, -1) {
+		b.WriteString(slackMarkdownText(s[last:loc[0]]))
+		b.WriteString(slackEscape(s[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	b.WriteString(slackMarkdownText(s[last:]))
+	return release(b.String())
+}
+
+func slackMarkdownText(s string) string {
+	s = slackEscape(s)
+	s = markdownBold.ReplaceAllString(s, bold+"$1"+bold)
+	s = markdownHeading.ReplaceAllString(s, bold+"$1"+bold)
+	s = markdownItalic.ReplaceAllString(s, "_${1}_")
+	s = markdownLink.ReplaceAllStringFunc(s, func(link string) string {
+		m := markdownLink.FindStringSubmatch(link)
+		text := strings.Replace(m[1], "|", "/", -1)
+		if len(text) == 0 {
+			return "<" + m[2] + ">"
+		}
+		return "<" + m[2] + "|" + text + ">"
+	})
+	return s
+}
+
+func tags(folders []string) string {
+	var tags []string
+	for _, f := range folders {
+		tags = append(tags, "`"+strings.Replace(f, "`", "'", -1)+"`")
+	}
+	return strings.Join(tags, " ")
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// DiscordEmbedField is a name and value shown in an embed.
+type DiscordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// DiscordEmbedFooter is the small text at the bottom of an embed.
+type DiscordEmbedFooter struct {
+	Text string `json:"text"`
+}
+
+// DiscordEmbed is a rich message in Discord.
+type DiscordEmbed struct {
+	Title       string              `json:"title"`
+	URL         string              `json:"url"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color,omitempty"`
+	Fields      []DiscordEmbedField `json:"fields,omitempty"`
+	Footer      *DiscordEmbedFooter `json:"footer,omitempty"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+}
+
+// DiscordMessage is the body of a Discord webhook request.
+type DiscordMessage struct {
+	Embeds []DiscordEmbed `json:"embeds"`
+}
+
+// Colors of Discord embeds, Piazza blue until an instructor answers.
+const (
+	discordColor         = 0x3e7aab
+	discordAnsweredColor = 0x2e9e4f
+)
+
+// Discord renders p, a post in class n, as a Discord message.
+func Discord(n piazza.Network, p piazza.Post, opts Options) DiscordMessage {
+	s := summarize(n, p, opts)
+	embed := DiscordEmbed{
+		Title:       truncate(s.title(), discordTitleLength),
+		URL:         s.url,
+		Description: truncate(s.body, discordDescriptionLength),
+		Color:       discordColor,
+	}
+	if len(s.folders) > 0 {
+		embed.Fields = append(embed.Fields, DiscordEmbedField{Name: "Folders", Value: tags(s.folders), Inline: true})
+	}
+	if s.instructorAnswer {
+		embed.Color = discordAnsweredColor
+		embed.Fields = append(embed.Fields, DiscordEmbedField{Name: "Answered", Value: "✅ Instructor answer", Inline: true})
+	}
+	if s.endorsements > 0 || s.answerEndorsements > 0 {
+		value := fmt.Sprintf("👍 %d", s.endorsements)
+		if s.answerEndorsements > 0 {
+			value += fmt.Sprintf(" · %d on answers", s.answerEndorsements)
+		}
+		embed.Fields = append(embed.Fields, DiscordEmbedField{Name: "Endorsements", Value: value, Inline: true})
+	}
+	if len(s.class) > 0 {
+		embed.Footer = &DiscordEmbedFooter{Text: s.class}
+	}
+	if !s.created.IsZero() {
+		embed.Timestamp = s.created.UTC().Format("2006-01-02T15:04:05Z07:00")
+	}
+	return DiscordMessage{Embeds: []DiscordEmbed{embed}}
+}
+
+// Send posts msg, a SlackMessage or DiscordMessage, to an incoming webhook.
+func Send(ctx context.Context, hookURL string, msg interface{}) error {
+	return SendWithOptions(ctx, hookURL, msg, Options{})
+}
+
+// SendWithOptions is Send with the HTTP client from opts.
+func SendWithOptions(ctx context.Context, hookURL string, msg interface{}, opts Options) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", hookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	client := opts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	reply, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("notify: webhook responded %s: %s", resp.Status, strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/notify"
+)
+
+var network = piazza.Network{
+	ID:           "n1",
+	Name:         "Computer Networking",
+	CourseNumber: "CPSC 317",
+	SchoolExt:    "ubc.ca",
+	Term:         "Winter Term 1 2016",
+	ShortNumber:  "cpsc317",
+}
+
+func post(t *testing.T) piazza.Post {
+	var p piazza.Post
+	if err := json.Unmarshal([]byte(`{
+		"id": "p1", "nr": 12, "type": "question", "folders": ["hw1", "exam"],
+		"created": "2016-09-06T20:32:57Z",
+		"history": [{"subject": "Checksums & <carries>", "content": "<md>Is **this** right? See [the notes](https://example.com/notes).</md>"}],
+		"tag_good": [{"id": "u2", "name": "Another Student"}],
+		"children": [
+			{"id": "a1", "type": "i_answer", "history": [{"content": "Yes."}],
+			 "tag_good": [{"id": "u1"}, {"id": "u3"}]},
+			{"id": "f1", "type": "followup", "subject": "Thanks!", "tag_good": [{"id": "u4"}]}
+		]
+	}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+const link = "https://piazza.com/ubc.ca/winterterm12016/cpsc317?cid=12"
+
+func TestSlack(t *testing.T) {
+	msg := notify.Slack(network, post(t), notify.Options{})
+	if msg.Text != "@12 Checksums & <carries>" || len(msg.Blocks) != 3 {
+		t.Fatalf("message = %+v", msg)
+	}
+	for i, want := range []string{
+		"*<" + link + "|@12 Checksums &amp; &lt;carries&gt;>*",
+		"Is *this* right? See <https://example.com/notes|the notes>.",
+		"CPSC 317  ·  `hw1` `exam`  ·  :white_check_mark: Instructor answer  ·  :+1: 1 endorsement  ·  :+1: 2 endorsements of answers",
+	} {
+		b := msg.Blocks[i]
+		text := ""
+		if b.Text != nil {
+			text = b.Text.Text
+		} else if len(b.Elements) == 1 {
+			text = b.Elements[0].Text
+		}
+		if text != want {
+			t.Errorf("block %d = %q; expected %q", i, text, want)
+		}
+	}
+	if msg.Blocks[2].Type != "context" {
+		t.Errorf("last block = %+v; expected context", msg.Blocks[2])
+	}
+}
+
+func TestSlackFormatting(t *testing.T) {
+	var p piazza.Post
+	if err := json.Unmarshal([]byte(`{
+		"id": "p2", "nr": 13, "type": "note",
+		"history": [{"subject": "Style", "content": "<p>An <em>important</em> <b>bold</b> note: 2*3 in snake_case, not <code>a*b*</code> or \\[x\\].</p>"}]
+	}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	msg := notify.Slack(network, p, notify.Options{})
+	if len(msg.Blocks) < 2 || msg.Blocks[1].Text == nil {
+		t.Fatalf("message = %+v", msg)
+	}
+	if got, want := msg.Blocks[1].Text.Text, "An _important_ *bold* note: 2*3 in snake_case, not `a*b*` or \\[x\\]."; got != want {
+		t.Errorf("body = %q; expected %q", got, want)
+	}
+
+	p.History[0].Content = "<p>1. Is a &lt;b&gt; &amp; c</p>"
+	msg = notify.Slack(network, p, notify.Options{})
+	if got, want := msg.Blocks[1].Text.Text, "1. Is a &lt;b&gt; &amp; c"; got != want {
+		t.Errorf("body = %q; expected %q", got, want)
+	}
+}
+
+func TestDiscord(t *testing.T) {
+	msg := notify.Discord(network, post(t), notify.Options{})
+	if len(msg.Embeds) != 1 {
+		t.Fatalf("message = %+v", msg)
+	}
+	e := msg.Embeds[0]
+	if e.Title != "@12 Checksums & <carries>" || e.URL != link ||
+		e.Description != "Is **this** right? See [the notes](https://example.com/notes)." ||
+		e.Footer == nil || e.Footer.Text != "CPSC 317" || e.Timestamp != "2016-09-06T20:32:57Z" {
+		t.Errorf("embed = %+v", e)
+	}
+	var fields []string
+	for _, f := range e.Fields {
+		fields = append(fields, f.Name+": "+f.Value)
+	}
+	if got, want := strings.Join(fields, "\n"), "Folders: `hw1` `exam`\nAnswered: ✅ Instructor answer\nEndorsements: 👍 1 · 2 on answers"; got != want {
+		t.Errorf("fields = %q; expected %q", got, want)
+	}
+
+	// Classes without a public page are linked by ID, and posts without
+	// answers or endorsements don't say so.
+	p := post(t)
+	p.Children = nil
+	p.TagGood = nil
+	p.History[0].Content = `<p>See <a href="/class/n2?cid=3">@3</a></p>`
+	e = notify.Discord(piazza.Network{ID: "n2"}, p, notify.Options{BaseURL: "http://localhost"}).Embeds[0]
+	if e.URL != "http://localhost/class/n2?cid=12" || len(e.Fields) != 1 || e.Footer != nil {
+		t.Errorf("embed = %+v", e)
+	}
+	// Relative links in the body point at BaseURL too.
+	if e.Description != "See [@3](http://localhost/class/n2?cid=3)" {
+		t.Errorf("body = %q", e.Description)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	p := post(t)
+	p.History[0].Content = "<md>" + strings.Repeat("word ", 20) + "\n```\ncode\ncode\n```</md>"
+	msg := notify.Discord(network, p, notify.Options{BodyLength: 110})
+	body := msg.Embeds[0].Description
+	if n := len([]rune(body)); n > 110+4 {
+		t.Errorf("body is %d characters: %q", n, body)
+	}
+	if !strings.Contains(body, "…") || strings.Count(body, "```")%2 != 0 {
+		t.Errorf("body = %q; expected it cut short with the code block closed", body)
+	}
+
+	msg = notify.Discord(network, p, notify.Options{BodyLength: 50})
+	if body := msg.Embeds[0].Description; body != strings.TrimSpace(strings.Repeat("word ", 9))+"…" {
+		t.Errorf("body = %q; expected it cut at a space", body)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got notify.SlackMessage
+	status := http.StatusOK
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte("invalid_payload"))
+	}))
+	defer s.Close()
+
+	ctx := context.Background()
+	msg := notify.Slack(network, post(t), notify.Options{})
+	if err := notify.Send(ctx, s.URL, msg); err != nil {
+		t.Fatal(err)
+	}
+	if got.Text != msg.Text || len(got.Blocks) != len(msg.Blocks) {
+		t.Errorf("server got %+v; expected %+v", got, msg)
+	}
+
+	status = http.StatusBadRequest
+	err := notify.SendWithOptions(ctx, s.URL, msg, notify.Options{HTTPClient: s.Client()})
+	if err == nil || !strings.Contains(err.Error(), "invalid_payload") {
+		t.Errorf("Send() = %v; expected the server's error", err)
+	}
+}
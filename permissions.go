@@ -0,0 +1,120 @@
+package piazza
+
+import (
+	"context"
+)
+
+// Permission is something a role may be allowed to do in a class. The values
+// are the keys of Network.Config.Roles.
+type Permission string
+
+// The permissions Piazza grants roles.
+const (
+	PermAdminRoster             Permission = "admin_roster"
+	PermCanPostAnonymousAll     Permission = "can_post_anonymous_all"
+	PermCanPostAnonymousMembers Permission = "can_post_anonymous_members"
+	PermExpertAnswerCreate      Permission = "expert_answer_create"
+	PermExpertAnswerEdit        Permission = "expert_answer_edit"
+	PermExpertAnswerEndorse     Permission = "expert_answer_endorse"
+	PermFollowupEdit            Permission = "followup_edit"
+	PermManageFolders           Permission = "manage_folders"
+	PermManageGroupInfo         Permission = "manage_group_info"
+	PermManageGroups            Permission = "manage_groups"
+	PermManageResources         Permission = "manage_resources"
+	PermMemberAnswerCreate      Permission = "member_answer_create"
+	PermMemberAnswerEdit        Permission = "member_answer_edit"
+	PermMemberAnswerEndorse     Permission = "member_answer_endorse"
+	PermMemberRoster            Permission = "member_roster"
+	PermNewFollowup             Permission = "new_followup"
+	PermNewPost                 Permission = "new_post"
+	PermQuestionDelete          Permission = "question_delete"
+	PermQuestionEdit            Permission = "question_edit"
+)
+
+// RolePermissions is what one role, such as "student" or "instructor", is
+// allowed to do in a class.
+type RolePermissions struct {
+	AdminRoster             bool `json:"admin_roster"`
+	CanPostAnonymousAll     bool `json:"can_post_anonymous_all"`
+	CanPostAnonymousMembers bool `json:"can_post_anonymous_members"`
+	ExpertAnswerCreate      bool `json:"expert_answer_create"`
+	ExpertAnswerEdit        bool `json:"expert_answer_edit"`
+	ExpertAnswerEndorse     bool `json:"expert_answer_endorse"`
+	FollowupEdit            bool `json:"followup_edit"`
+	ManageFolders           bool `json:"manage_folders"`
+	ManageGroupInfo         bool `json:"manage_group_info"`
+	ManageGroups            bool `json:"manage_groups"`
+	ManageResources         bool `json:"manage_resources"`
+	MemberAnswerCreate      bool `json:"member_answer_create"`
+	MemberAnswerEdit        bool `json:"member_answer_edit"`
+	MemberAnswerEndorse     bool `json:"member_answer_endorse"`
+	MemberRoster            bool `json:"member_roster"`
+	NewFollowup             bool `json:"new_followup"`
+	NewPost                 bool `json:"new_post"`
+	QuestionDelete          bool `json:"question_delete"`
+	QuestionEdit            bool `json:"question_edit"`
+}
+
+// Has reports whether perm is granted. Unknown permissions never are.
+func (p RolePermissions) Has(perm Permission) bool {
+	switch perm {
+	case PermAdminRoster:
+		return p.AdminRoster
+	case PermCanPostAnonymousAll:
+		return p.CanPostAnonymousAll
+	case PermCanPostAnonymousMembers:
+		return p.CanPostAnonymousMembers
+	case PermExpertAnswerCreate:
+		return p.ExpertAnswerCreate
+	case PermExpertAnswerEdit:
+		return p.ExpertAnswerEdit
+	case PermExpertAnswerEndorse:
+		return p.ExpertAnswerEndorse
+	case PermFollowupEdit:
+		return p.FollowupEdit
+	case PermManageFolders:
+		return p.ManageFolders
+	case PermManageGroupInfo:
+		return p.ManageGroupInfo
+	case PermManageGroups:
+		return p.ManageGroups
+	case PermManageResources:
+		return p.ManageResources
+	case PermMemberAnswerCreate:
+		return p.MemberAnswerCreate
+	case PermMemberAnswerEdit:
+		return p.MemberAnswerEdit
+	case PermMemberAnswerEndorse:
+		return p.MemberAnswerEndorse
+	case PermMemberRoster:
+		return p.MemberRoster
+	case PermNewFollowup:
+		return p.NewFollowup
+	case PermNewPost:
+		return p.NewPost
+	case PermQuestionDelete:
+		return p.QuestionDelete
+	case PermQuestionEdit:
+		return p.QuestionEdit
+	}
+	return false
+}
+
+// Can reports whether role has perm in the class.
+func (n Network) Can(role string, perm Permission) bool {
+	return n.Config.Roles[role].Has(perm)
+}
+
+// MyPermissions returns what the logged in user's role allows in the class
+// nid.
+func (c *Client) MyPermissions(ctx context.Context, nid string) (RolePermissions, error) {
+	status, err := c.UserStatusContext(ctx)
+	if err != nil {
+		return RolePermissions{}, err
+	}
+	n, err := status.network(nid)
+	if err != nil {
+		return RolePermissions{}, err
+	}
+	return n.Config.Roles[status.Result.Config.Roles[nid]], nil
+}
@@ -2,11 +2,15 @@ package piazza
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/headzoo/surf"
@@ -14,52 +18,225 @@ import (
 	"github.com/pkg/errors"
 )
 
+// DefaultBaseURL is the root of the Piazza site.
+const DefaultBaseURL = `https://piazza.com`
+
 // LoginURL is the URL you need to login.
-const LoginURL = `https://piazza.com/account/login`
+const LoginURL = DefaultBaseURL + `/account/login`
 
 // Client represents a client to the piazza API.
 type Client struct {
-	bow *browser.Browser
-	aid string
+	http     *http.Client
+	base     *url.URL
+	cookies  *cookieLog
+	loginURL string
+	ua       string
+
+	// bowMu serializes navigation since the browser is stateful.
+	bowMu     sync.Mutex
+	bow       *browser.Browser
+	transport http.RoundTripper
+
+	renew   bool
+	onRenew func(error)
+	// loginMu serializes logins so an expired session is only renewed once.
+	loginMu sync.Mutex
+
+	mu       sync.Mutex
+	aid      string
+	username string
+	password string
+	session  int                        // bumped on every successful login
+	feeds    map[string][]FeedItem      // last complete feed of each class
+	users    map[string]map[string]User // by class and then user ID
 }
 
-// MakeClient returns a new logged in client.
-func MakeClient(username, password string) (*Client, error) {
-	c := &Client{
-		bow: surf.NewBrowser(),
-	}
-	return c, c.Login(username, password)
+// ClientOptions configures how a Client talks to Piazza. The zero value talks
+// to piazza.com through http.DefaultClient.
+type ClientOptions struct {
+	// BaseURL is the root of the site, e.g. "https://piazza.com". API requests
+	// go to BaseURL + "/logic/api".
+	BaseURL string
+	// LoginURL is the page that serves form#login-form. It defaults to
+	// BaseURL + "/account/login".
+	LoginURL string
+	// HTTPClient is used for API requests. Its Transport is also used by the
+	// browser that performs the form login.
+	HTTPClient *http.Client
+	// UserAgent overrides the User-Agent header if set.
+	UserAgent string
+	// Jar holds the session cookies. If nil, HTTPClient.Jar is used, and
+	// failing that a new in-memory jar.
+	Jar http.CookieJar
+	// RenewSession makes the client remember the credentials passed to Login.
+	// An API call that finds the session expired then logs in again and is
+	// retried once.
+	RenewSession bool
+	// OnRenew, if set, is called with the result of every automatic login.
+	OnRenew func(err error)
 }
 
-// Login logs into Piazza with the specified username and password.
-func (c *Client) Login(username, password string) error {
-	if err := c.bow.Open(LoginURL); err != nil {
-		return err
+// NewClient returns a client that isn't logged in yet.
+func NewClient(opts ClientOptions) (*Client, error) {
+	baseURL := opts.BaseURL
+	if len(baseURL) == 0 {
+		baseURL = DefaultBaseURL
 	}
-
-	// Log in to the site.
-	fm, err := c.bow.Form("form#login-form")
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
 	if err != nil {
-		return err
+		return nil, errors.Wrapf(err, "base url %q", baseURL)
 	}
-	if err := fm.Input("email", username); err != nil {
-		return err
+	loginURL := opts.LoginURL
+	if len(loginURL) == 0 {
+		loginURL = base.String() + "/account/login"
 	}
-	if err := fm.Input("password", password); err != nil {
-		return err
+
+	httpClient := http.Client{}
+	if opts.HTTPClient != nil {
+		httpClient = *opts.HTTPClient
+	}
+	jar := opts.Jar
+	if jar == nil {
+		jar = httpClient.Jar
+	}
+	if jar == nil {
+		if jar, err = cookiejar.New(nil); err != nil {
+			return nil, err
+		}
 	}
-	if err := fm.Submit(); err != nil {
+	cookies := &cookieLog{CookieJar: jar}
+	httpClient.Jar = cookies
+
+	transport := httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	bow := surf.NewBrowser()
+	bow.SetCookieJar(cookies)
+	if len(opts.UserAgent) > 0 {
+		bow.SetUserAgent(opts.UserAgent)
+	}
+
+	return &Client{
+		http:      &httpClient,
+		base:      base,
+		cookies:   cookies,
+		loginURL:  loginURL,
+		ua:        opts.UserAgent,
+		bow:       bow,
+		transport: transport,
+		renew:     opts.RenewSession,
+		onRenew:   opts.OnRenew,
+	}, nil
+}
+
+// ctxTransport attaches a context to every request the browser makes, since
+// surf has no notion of one.
+type ctxTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t ctxTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// browse runs f with exclusive use of the browser, cancelling any requests it
+// makes when ctx is done.
+func (c *Client) browse(ctx context.Context, f func(bow *browser.Browser) error) error {
+	c.bowMu.Lock()
+	defer c.bowMu.Unlock()
+
+	c.bow.SetTransport(ctxTransport{ctx: ctx, base: c.transport})
+	if err := f(c.bow); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		return err
 	}
-	code := c.bow.StatusCode()
-	if code != 200 {
-		return errors.Errorf("StatusCode = %d", code)
+	return nil
+}
+
+// MakeClient returns a new logged in client.
+func MakeClient(username, password string) (*Client, error) {
+	return MakeClientWithOptions(username, password, ClientOptions{})
+}
+
+// MakeClientWithOptions returns a new logged in client configured by opts.
+func MakeClientWithOptions(username, password string, opts ClientOptions) (*Client, error) {
+	return MakeClientContext(context.Background(), username, password, opts)
+}
+
+// MakeClientContext is like MakeClientWithOptions but aborts the login when
+// ctx is done.
+func MakeClientContext(ctx context.Context, username, password string, opts ClientOptions) (*Client, error) {
+	c, err := NewClient(opts)
+	if err != nil {
+		return nil, err
 	}
-	errText := c.bow.Dom().Find("#modal_error_text").Text()
-	if len(errText) > 0 {
-		return errors.Errorf(errText)
+	return c, c.LoginContext(ctx, username, password)
+}
+
+// BaseURL returns the root of the site the client talks to.
+func (c *Client) BaseURL() string {
+	return c.base.String()
+}
+
+// Login logs into Piazza with the specified username and password.
+func (c *Client) Login(username, password string) error {
+	return c.LoginContext(context.Background(), username, password)
+}
+
+// LoginContext is like Login but aborts when ctx is done.
+func (c *Client) LoginContext(ctx context.Context, username, password string) error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+	return c.login(ctx, username, password)
+}
+
+// login does the work of LoginContext. The caller must hold loginMu.
+func (c *Client) login(ctx context.Context, username, password string) error {
+	if err := c.browse(ctx, func(bow *browser.Browser) error {
+		if err := bow.Open(c.loginURL); err != nil {
+			return err
+		}
+
+		// Log in to the site.
+		fm, err := bow.Form("form#login-form")
+		if err != nil {
+			return err
+		}
+		if err := fm.Input("email", username); err != nil {
+			return err
+		}
+		if err := fm.Input("password", password); err != nil {
+			return err
+		}
+		if err := fm.Submit(); err != nil {
+			return err
+		}
+		code := bow.StatusCode()
+		if code != 200 {
+			return errors.Errorf("StatusCode = %d", code)
+		}
+		errText := bow.Dom().Find("#modal_error_text").Text()
+		if len(errText) > 0 {
+			return errors.New(errText)
+		}
+
+		return nil
+	}); err != nil {
+		return err
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session++
+	if c.renew {
+		c.username = username
+		c.password = password
+	}
 	return nil
 }
 
@@ -79,7 +256,7 @@ func (c *Client) Login(username, password string) error {
 type Resource struct {
 	Content string `json:"content"`
 	Subject string `json:"subject"`
-	Created string `json:"created"`
+	Created Time   `json:"created"`
 	ID      string `json:"id"`
 	Config  struct {
 		ResourceType string `json:"resource_type"`
@@ -90,18 +267,28 @@ type Resource struct {
 
 // FetchResources returns all the resources for a class.
 func (c *Client) FetchResources(classResourceURL string) ([]Resource, error) {
-	if err := c.bow.Open(classResourceURL); err != nil {
-		return nil, err
-	}
+	return c.FetchResourcesContext(context.Background(), classResourceURL)
+}
+
+// FetchResourcesContext is like FetchResources but aborts when ctx is done.
+func (c *Client) FetchResourcesContext(ctx context.Context, classResourceURL string) ([]Resource, error) {
 	body := ""
-	c.bow.Find("script").Each(func(_ int, s *goquery.Selection) {
-		text := s.Text()
-		parts := strings.Split(text, "this.resource_data        = ")
-		if len(parts) != 2 {
-			return
+	if err := c.browse(ctx, func(bow *browser.Browser) error {
+		if err := bow.Open(classResourceURL); err != nil {
+			return err
 		}
-		body = strings.Split(parts[1], ";\n")[0]
-	})
+		bow.Find("script").Each(func(_ int, s *goquery.Selection) {
+			text := s.Text()
+			parts := strings.Split(text, "this.resource_data        = ")
+			if len(parts) != 2 {
+				return
+			}
+			body = strings.Split(parts[1], ";\n")[0]
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
 	data := []Resource{}
 	if err := json.Unmarshal([]byte(body), &data); err != nil {
 		return nil, err
@@ -110,7 +297,9 @@ func (c *Client) FetchResources(classResourceURL string) ([]Resource, error) {
 }
 
 // APIEndpoint is the endpoint for all APIs.
-const APIEndpoint = "https://piazza.com/logic/api?method="
+const APIEndpoint = DefaultBaseURL + apiPath
+
+const apiPath = "/logic/api?method="
 
 // ContentType is the content type for all API requests.
 const ContentType = "application/json; charset=UTF-8"
@@ -121,21 +310,39 @@ type APIReq struct {
 	Params interface{} `json:"params"`
 }
 
-func (c *Client) setCookies(req *http.Request) {
-	for _, c := range c.Cookies() {
-		req.AddCookie(c)
+// MakeAPIReq calls the API method with params and decodes the reply into resp.
+// resp may be nil if the reply isn't needed.
+func (c *Client) MakeAPIReq(method string, params interface{}, resp interface{}) error {
+	return c.MakeAPIReqContext(context.Background(), method, params, resp)
+}
+
+// MakeAPIReqContext is like MakeAPIReq but aborts when ctx is done.
+func (c *Client) MakeAPIReqContext(ctx context.Context, method string, params interface{}, resp interface{}) error {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+
+	err := c.apiReq(ctx, method, params, resp)
+	if !c.renew || !isKind(err, ErrNotLoggedIn) {
+		return err
 	}
+	if err := c.renewSession(ctx, session, err); err != nil {
+		return err
+	}
+	return c.apiReq(ctx, method, params, resp)
 }
 
-func (c *Client) MakeAPIReq(method string, params interface{}, resp interface{}) error {
+func (c *Client) apiReq(ctx context.Context, method string, params interface{}, resp interface{}) error {
 	req := APIReq{
 		Method: method,
 		Params: params,
 	}
-	url := APIEndpoint + method
+	url := c.base.String() + apiPath + method
+	c.mu.Lock()
 	if len(c.aid) > 0 {
 		url += "&aid=" + c.aid
 	}
+	c.mu.Unlock()
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(req); err != nil {
 		return err
@@ -144,24 +351,39 @@ func (c *Client) MakeAPIReq(method string, params interface{}, resp interface{})
 	if err != nil {
 		return err
 	}
-	c.setCookies(httpReq)
+	httpReq = httpReq.WithContext(ctx)
 	httpReq.Header.Add("Content-Type", ContentType)
-	httpResp, err := http.DefaultClient.Do(httpReq)
+	if len(c.ua) > 0 {
+		httpReq.Header.Set("User-Agent", c.ua)
+	}
+	httpResp, err := c.http.Do(httpReq)
 	if err != nil {
 		return err
 	}
 	defer httpResp.Body.Close()
 
+	if httpResp.Request.URL.Path == c.loginPath() {
+		return &APIError{Method: method, Message: "redirected to the login page", Kind: ErrNotLoggedIn}
+	}
+	if httpResp.StatusCode == http.StatusTooManyRequests {
+		return &APIError{Method: method, Message: httpResp.Status, Kind: ErrRateLimited}
+	}
 	if httpResp.StatusCode != 200 {
 		return errors.Errorf("StatusCode = %d", httpResp.StatusCode)
 	}
 
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "method %q", method)
+	}
+	if err := checkEnvelope(method, body); err != nil {
+		return err
+	}
+
 	if resp == nil {
 		return nil
 	}
 
-	body, _ := ioutil.ReadAll(httpResp.Body)
-
 	if err := json.Unmarshal(body, resp); err != nil {
 		return errors.Wrapf(err, "method %q", method)
 	}
@@ -170,13 +392,18 @@ func (c *Client) MakeAPIReq(method string, params interface{}, resp interface{})
 }
 
 // EmailPrefs is the UserStatus.Result.Config subfield relating to email prefs.
-// There is one extra "careers" field.
-type EmailPrefs map[string]struct {
+// It's keyed by class ID, with one extra "career" entry.
+type EmailPrefs map[string]EmailPref
+
+// EmailPref is what Piazza emails a user about in one class.
+type EmailPref struct {
 	AutoFollow interface{} `json:"auto_follow"`
-	New        string      `json:"new"`
-	Updates    string      `json:"updates"`
-	NoEvents   bool        `json:"no_events"`
-	Throttle   int         `json:"throttle"`
+	// New is how often to email about new posts, and Updates about posts the
+	// user follows.
+	New      EmailFrequency `json:"new"`
+	Updates  EmailFrequency `json:"updates"`
+	NoEvents bool           `json:"no_events"`
+	Throttle int            `json:"throttle"`
 }
 
 // Network is a single one of the users network. For some reason this seems to
@@ -217,102 +444,16 @@ type Network struct {
 			Title      string `json:"title"`
 			Visibility bool   `json:"visibility"`
 		} `json:"resource_sections"`
-		Roles struct {
-			Admin struct {
-				AdminRoster             bool `json:"admin_roster"`
-				CanPostAnonymousAll     bool `json:"can_post_anonymous_all"`
-				CanPostAnonymousMembers bool `json:"can_post_anonymous_members"`
-				ExpertAnswerCreate      bool `json:"expert_answer_create"`
-				ExpertAnswerEdit        bool `json:"expert_answer_edit"`
-				ExpertAnswerEndorse     bool `json:"expert_answer_endorse"`
-				FollowupEdit            bool `json:"followup_edit"`
-				ManageFolders           bool `json:"manage_folders"`
-				ManageGroupInfo         bool `json:"manage_group_info"`
-				ManageGroups            bool `json:"manage_groups"`
-				ManageResources         bool `json:"manage_resources"`
-				MemberAnswerCreate      bool `json:"member_answer_create"`
-				MemberAnswerEdit        bool `json:"member_answer_edit"`
-				MemberAnswerEndorse     bool `json:"member_answer_endorse"`
-				MemberRoster            bool `json:"member_roster"`
-				NewFollowup             bool `json:"new_followup"`
-				NewPost                 bool `json:"new_post"`
-				QuestionDelete          bool `json:"question_delete"`
-				QuestionEdit            bool `json:"question_edit"`
-			} `json:"admin"`
-			Instructor struct {
-				AdminRoster         bool `json:"admin_roster"`
-				ExpertAnswerCreate  bool `json:"expert_answer_create"`
-				ExpertAnswerEdit    bool `json:"expert_answer_edit"`
-				ExpertAnswerEndorse bool `json:"expert_answer_endorse"`
-				FollowupEdit        bool `json:"followup_edit"`
-				ManageFolders       bool `json:"manage_folders"`
-				ManageGroupInfo     bool `json:"manage_group_info"`
-				ManageGroups        bool `json:"manage_groups"`
-				ManageResources     bool `json:"manage_resources"`
-				MemberAnswerEdit    bool `json:"member_answer_edit"`
-				MemberAnswerEndorse bool `json:"member_answer_endorse"`
-				MemberRoster        bool `json:"member_roster"`
-				NewFollowup         bool `json:"new_followup"`
-				NewPost             bool `json:"new_post"`
-				QuestionDelete      bool `json:"question_delete"`
-				QuestionEdit        bool `json:"question_edit"`
-			} `json:"instructor"`
-			Professor struct {
-				AdminRoster         bool `json:"admin_roster"`
-				ExpertAnswerCreate  bool `json:"expert_answer_create"`
-				ExpertAnswerEdit    bool `json:"expert_answer_edit"`
-				ExpertAnswerEndorse bool `json:"expert_answer_endorse"`
-				FollowupEdit        bool `json:"followup_edit"`
-				ManageFolders       bool `json:"manage_folders"`
-				ManageGroupInfo     bool `json:"manage_group_info"`
-				ManageGroups        bool `json:"manage_groups"`
-				ManageResources     bool `json:"manage_resources"`
-				MemberAnswerEdit    bool `json:"member_answer_edit"`
-				MemberAnswerEndorse bool `json:"member_answer_endorse"`
-				MemberRoster        bool `json:"member_roster"`
-				NewFollowup         bool `json:"new_followup"`
-				NewPost             bool `json:"new_post"`
-				QuestionDelete      bool `json:"question_delete"`
-				QuestionEdit        bool `json:"question_edit"`
-			} `json:"professor"`
-			Student struct {
-				CanPostAnonymousMembers bool `json:"can_post_anonymous_members"`
-				ExpertAnswerEndorse     bool `json:"expert_answer_endorse"`
-				MemberAnswerCreate      bool `json:"member_answer_create"`
-				MemberAnswerEdit        bool `json:"member_answer_edit"`
-				MemberAnswerEndorse     bool `json:"member_answer_endorse"`
-				NewFollowup             bool `json:"new_followup"`
-				NewPost                 bool `json:"new_post"`
-				QuestionEdit            bool `json:"question_edit"`
-			} `json:"student"`
-			Ta struct {
-				AdminRoster         bool `json:"admin_roster"`
-				ExpertAnswerCreate  bool `json:"expert_answer_create"`
-				ExpertAnswerEdit    bool `json:"expert_answer_edit"`
-				ExpertAnswerEndorse bool `json:"expert_answer_endorse"`
-				FollowupEdit        bool `json:"followup_edit"`
-				ManageFolders       bool `json:"manage_folders"`
-				ManageGroupInfo     bool `json:"manage_group_info"`
-				ManageGroups        bool `json:"manage_groups"`
-				ManageResources     bool `json:"manage_resources"`
-				MemberAnswerEdit    bool `json:"member_answer_edit"`
-				MemberAnswerEndorse bool `json:"member_answer_endorse"`
-				MemberRoster        bool `json:"member_roster"`
-				NewFollowup         bool `json:"new_followup"`
-				NewPost             bool `json:"new_post"`
-				QuestionDelete      bool `json:"question_delete"`
-				QuestionEdit        bool `json:"question_edit"`
-			} `json:"ta"`
-		} `json:"roles"`
-		SeenMessage  []string `json:"seen_message"`
-		TipsTricksNr int      `json:"tips_tricks_nr"`
+		Roles        map[string]RolePermissions `json:"roles"`
+		SeenMessage  []string                   `json:"seen_message"`
+		TipsTricksNr int                        `json:"tips_tricks_nr"`
 	} `json:"config"`
 	CourseDescription  string         `json:"course_description"`
 	CourseNumber       string         `json:"course_number"`
-	CreatedAt          string         `json:"created_at"`
+	CreatedAt          Time           `json:"created_at"`
 	CreatorName        string         `json:"creator_name"`
 	Department         string         `json:"department"`
-	EndDate            interface{}    `json:"end_date"`
+	EndDate            Time           `json:"end_date"`
 	Enrollment         interface{}    `json:"enrollment"`
 	Folders            []string       `json:"folders"`
 	GeneralInformation []interface{}  `json:"general_information"`
@@ -342,7 +483,7 @@ type Network struct {
 	SchoolShort      string        `json:"school_short"`
 	ShortNumber      string        `json:"short_number"`
 	SpecialTags      []interface{} `json:"special_tags"`
-	StartDate        string        `json:"start_date"`
+	StartDate        Time          `json:"start_date"`
 	Status           string        `json:"status"`
 	Syllabus         string        `json:"syllabus"`
 	Taxonomy         []interface{} `json:"taxonomy"`
@@ -354,10 +495,22 @@ type Network struct {
 	UserCount        int           `json:"user_count"`
 }
 
-// ResourceURL returns the resource URL for the course.
+// ResourceURL returns the resource URL for the course on piazza.com.
+//
+// Deprecated: use Client.ResourceURL, which honours ClientOptions.BaseURL.
 func (n Network) ResourceURL() string {
+	return n.resourceURL(DefaultBaseURL)
+}
+
+func (n Network) resourceURL(base string) string {
 	term := strings.ToLower(strings.Replace(n.Term, " ", "", -1))
-	return fmt.Sprintf("https://piazza.com/%s/%s/%s/home", n.SchoolExt, term, n.ShortNumber)
+	return fmt.Sprintf("%s/%s/%s/%s/home", base, n.SchoolExt, term, n.ShortNumber)
+}
+
+// ResourceURL returns the resource URL for the course on the site the client
+// talks to.
+func (c *Client) ResourceURL(n Network) string {
+	return n.resourceURL(c.base.String())
 }
 
 // UserStatus contains all the fields that the UserStatusAPI endpoint returns.
@@ -376,20 +529,20 @@ type UserStatus struct {
 		Config struct {
 			CareersNotifications []struct {
 				Content               string `json:"content"`
-				CreatedAt             int    `json:"created_at"`
+				CreatedAt             Time   `json:"created_at"`
 				ID                    string `json:"id"`
 				ImageSource           string `json:"image_source"`
 				Link                  string `json:"link"`
-				NotificationEventTime int    `json:"notification_event_time"`
+				NotificationEventTime Time   `json:"notification_event_time"`
 				Status                string `json:"status"`
 				Type                  string `json:"type"`
-				UpdatedAt             int    `json:"updated_at"`
+				UpdatedAt             Time   `json:"updated_at"`
 			} `json:"careers_notifications"`
-			EmailPrefs        EmailPrefs     `json:"email_prefs"`
-			EmailThrottle     map[string]int `json:"email_throttle"`
-			EmailThrottleLast map[string]int `json:"email_throttle_last"`
-			EnrollTime        map[string]int `json:"enroll_time"`
-			F15EDigest0125    int            `json:"f15_e_digest0125"`
+			EmailPrefs        EmailPrefs      `json:"email_prefs"`
+			EmailThrottle     map[string]int  `json:"email_throttle"`
+			EmailThrottleLast map[string]Time `json:"email_throttle_last"`
+			EnrollTime        map[string]Time `json:"enroll_time"`
+			F15EDigest0125    int             `json:"f15_e_digest0125"`
 			Feed              struct {
 				Following  int `json:"following"`
 				Unread     int `json:"unread"`
@@ -399,7 +552,7 @@ type UserStatus struct {
 			FeedDetails string `json:"feed_details"`
 			FeedDigest  struct {
 				State string `json:"state"`
-				When  int    `json:"when"`
+				When  Time   `json:"when"`
 			} `json:"feed_digest"`
 			FeedGroups                     []string `json:"feed_groups"`
 			InRoster                       []string `json:"in_roster"`
@@ -408,14 +561,14 @@ type UserStatus struct {
 			Logins                         int      `json:"logins"`
 			NoFeed                         bool     `json:"no_feed"`
 			NotificationTypeLastCalculated struct {
-				AppearedInSearch      int `json:"appeared_in_search"`
-				ClassmatesCompanyView int `json:"classmates_company_view"`
-				CompaniesOnline       int `json:"companies_online"`
-				NewCompany            int `json:"new_company"`
-				UpcomingEvents        int `json:"upcoming_events"`
+				AppearedInSearch      Time `json:"appeared_in_search"`
+				ClassmatesCompanyView Time `json:"classmates_company_view"`
+				CompaniesOnline       Time `json:"companies_online"`
+				NewCompany            Time `json:"new_company"`
+				UpcomingEvents        Time `json:"upcoming_events"`
 			} `json:"notification_type_last_calculated"`
 			Published           bool              `json:"published"`
-			PublishedTime       string            `json:"published_time"`
+			PublishedTime       Time              `json:"published_time"`
 			Roles               map[string]string `json:"roles"`
 			SeenMessage         []string          `json:"seen_message"`
 			TechtourViewCounter int               `json:"techtour_view_counter"`
@@ -431,44 +584,14 @@ type UserStatus struct {
 			AvgCnt interface{} `json:"avg_cnt"`
 			Draft  struct{}    `json:"draft"`
 			Drafts struct{}    `json:"drafts"`
-			Feed   []struct {
-				BucketName    string        `json:"bucket_name"`
-				BucketOrder   int           `json:"bucket_order"`
-				ContentSnipet string        `json:"content_snipet"`
-				Fol           string        `json:"fol"`
-				Folders       []interface{} `json:"folders"`
-				ID            string        `json:"id"`
-				IsNew         bool          `json:"is_new"`
-				Log           []struct {
-					N string `json:"n"`
-					T string `json:"t"`
-					U string `json:"u"`
-				} `json:"log"`
-				M                 int      `json:"m"`
-				MainVersion       int      `json:"main_version"`
-				Modified          string   `json:"modified"`
-				NoAnswerFollowup  int      `json:"no_answer_followup"`
-				Nr                int      `json:"nr"`
-				NumFavorites      int      `json:"num_favorites"`
-				Pin               int      `json:"pin"`
-				RequestInstructor int      `json:"request_instructor"`
-				Rq                int      `json:"rq"`
-				Score             float64  `json:"score"`
-				Status            string   `json:"status"`
-				Subject           string   `json:"subject"`
-				Tags              []string `json:"tags"`
-				Type              string   `json:"type"`
-				UniqueViews       int      `json:"unique_views"`
-				Updated           string   `json:"updated"`
-				ViewAdjust        int      `json:"view_adjust"`
-			} `json:"feed"`
-			Hof struct {
+			Feed   []FeedItem  `json:"feed"`
+			Hof    struct {
 				BestAnswer []struct {
 					Nr   int         `json:"nr"`
 					Text string      `json:"text"`
-					Time int         `json:"time"`
+					Time Time        `json:"time"`
 					UID  interface{} `json:"uid"`
-					When int         `json:"when"`
+					When Time        `json:"when"`
 				} `json:"best_answer"`
 			} `json:"hof"`
 			LastNetworks         []string      `json:"last_networks"`
@@ -523,35 +646,30 @@ type UserStatus struct {
 
 // UserStatus returns the user status.
 func (c *Client) UserStatus() (UserStatus, error) {
+	return c.UserStatusContext(context.Background())
+}
+
+// UserStatusContext is like UserStatus but aborts when ctx is done.
+func (c *Client) UserStatusContext(ctx context.Context) (UserStatus, error) {
 	var resp UserStatus
-	if err := c.MakeAPIReq("user.status", struct{}{}, &resp); err != nil {
+	if err := c.MakeAPIReqContext(ctx, "user.status", struct{}{}, &resp); err != nil {
 		return UserStatus{}, err
 	}
+	c.mu.Lock()
 	c.aid = resp.Aid
+	c.mu.Unlock()
 	return resp, nil
 }
 
-type updateEmailsReq struct {
-	EmailPrefs EmailPrefs `json:"email_prefs"`
-}
-
 // OptOutOfEmails sets `new: "no-emails"` on all courses.
 func (c *Client) OptOutOfEmails() error {
-	status, err := c.UserStatus()
-	if err != nil {
-		return err
-	}
-	prefs := status.Result.Config.EmailPrefs
-	delete(prefs, "career")
-	for c, pref := range prefs {
-		pref.New = "no-emails"
-		prefs[c] = pref
-	}
-	req := updateEmailsReq{prefs}
-	if err := c.MakeAPIReq("user.update", req, nil); err != nil {
-		return err
-	}
-	return nil
+	return c.OptOutOfEmailsContext(context.Background())
+}
+
+// OptOutOfEmailsContext is like OptOutOfEmails but aborts when ctx is done.
+func (c *Client) OptOutOfEmailsContext(ctx context.Context) error {
+	_, err := c.SetEmailPrefs(ctx, "", EmailPrefUpdate{New: EmailNone})
+	return err
 }
 
 type feedReq struct {
@@ -561,46 +679,48 @@ type feedReq struct {
 	Sort   string `json:"sort"`
 }
 
+// FeedItem is the summary of a post that feeds list. Both feed requests and
+// UserStatus's FeedPrefetch use it.
+type FeedItem struct {
+	BucketName        string         `json:"bucket_name"`
+	BucketOrder       int            `json:"bucket_order"`
+	ContentSnipet     string         `json:"content_snipet"`
+	Fol               string         `json:"fol"`
+	Folders           []string       `json:"folders"`
+	Gd                int            `json:"gd"`
+	ID                string         `json:"id"`
+	IsNew             bool           `json:"is_new"`
+	Log               []FeedLogEntry `json:"log"`
+	M                 int            `json:"m"`
+	MainVersion       int            `json:"main_version"`
+	Modified          Time           `json:"modified"`
+	NoAnswer          int            `json:"no_answer"`
+	NoAnswerFollowup  int            `json:"no_answer_followup"`
+	Nr                int            `json:"nr"`
+	NumFavorites      int            `json:"num_favorites"`
+	Pin               int            `json:"pin"`
+	RequestInstructor int            `json:"request_instructor"`
+	Rq                int            `json:"rq"`
+	Score             float64        `json:"score"`
+	Status            string         `json:"status"`
+	Subject           string         `json:"subject"`
+	Tags              []string       `json:"tags"`
+	Type              string         `json:"type"`
+	UniqueViews       int            `json:"unique_views"`
+	Updated           Time           `json:"updated"`
+	ViewAdjust        int            `json:"view_adjust"`
+}
+
 // FeedResponse is what "network.get_my_feed" returns.
 type FeedResponse struct {
 	Aid    string      `json:"aid"`
 	Error  interface{} `json:"error"`
 	Result struct {
-		Draft struct{} `json:"draft"`
-		Feed  []struct {
-			BucketName    string   `json:"bucket_name"`
-			BucketOrder   int      `json:"bucket_order"`
-			ContentSnipet string   `json:"content_snipet"`
-			Fol           string   `json:"fol"`
-			Folders       []string `json:"folders"`
-			Gd            int      `json:"gd"`
-			ID            string   `json:"id"`
-			IsNew         bool     `json:"is_new"`
-			Log           []struct {
-				N string `json:"n"`
-				T string `json:"t"`
-				U string `json:"u"`
-			} `json:"log"`
-			M                 int      `json:"m"`
-			MainVersion       int      `json:"main_version"`
-			Modified          string   `json:"modified"`
-			NoAnswerFollowup  int      `json:"no_answer_followup"`
-			Nr                int      `json:"nr"`
-			NumFavorites      int      `json:"num_favorites"`
-			RequestInstructor int      `json:"request_instructor"`
-			Rq                int      `json:"rq"`
-			Score             float64  `json:"score"`
-			Status            string   `json:"status"`
-			Subject           string   `json:"subject"`
-			Tags              []string `json:"tags"`
-			Type              string   `json:"type"`
-			UniqueViews       int      `json:"unique_views"`
-			Updated           string   `json:"updated"`
-			ViewAdjust        int      `json:"view_adjust"`
-		} `json:"feed"`
-		More      bool   `json:"more"`
-		Sort      string `json:"sort"`
-		T         int    `json:"t"`
+		Draft     struct{}   `json:"draft"`
+		Feed      []FeedItem `json:"feed"`
+		More      bool       `json:"more"`
+		Sort      string     `json:"sort"`
+		T         int        `json:"t"`
 		TokenData struct {
 			ChannelIds []string `json:"channel_ids"`
 			Signature  string   `json:"signature"`
@@ -609,17 +729,48 @@ type FeedResponse struct {
 	} `json:"result"`
 }
 
+// cachedFeed returns the items from the last call to Feed for a class.
+func (c *Client) cachedFeed(class string) ([]FeedItem, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	items, ok := c.feeds[class]
+	return items, ok
+}
+
 // Feed requests all feed elements for a class.
 func (c *Client) Feed(class string) (FeedResponse, error) {
-	req := feedReq{Limit: 1000000, Offset: 0, Sort: "updated", Nid: class}
-	var resp FeedResponse
-	if err := c.MakeAPIReq("network.get_my_feed", req, &resp); err != nil {
+	return c.FeedContext(context.Background(), class)
+}
+
+// FeedContext is like Feed but aborts when ctx is done. The feed is fetched a
+// page at a time; use FeedPages to avoid holding all of it in memory.
+func (c *Client) FeedContext(ctx context.Context, class string) (FeedResponse, error) {
+	it := c.FeedPages(ctx, class, FeedOptions{})
+	var items []FeedItem
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	if err := it.Err(); err != nil {
 		return FeedResponse{}, err
 	}
+	resp := it.Response()
+	resp.Result.Feed = items
+	resp.Result.More = false
+
+	c.mu.Lock()
+	if c.feeds == nil {
+		c.feeds = map[string][]FeedItem{}
+	}
+	c.feeds[class] = items
+	c.mu.Unlock()
+
 	return resp, nil
 }
 
 type Post struct {
+	// Anon, Subject and UID are only set on followups and feedback, which
+	// keep their text in Subject rather than in History.
+	Anon        string `json:"anon"`
 	Bookmarked  int    `json:"bookmarked"`
 	BucketName  string `json:"bucket_name"`
 	BucketOrder int    `json:"bucket_order"`
@@ -628,11 +779,11 @@ type Post struct {
 		Data string `json:"data"`
 		Type string `json:"type"`
 		UID  string `json:"uid"`
-		When string `json:"when"`
+		When Time   `json:"when"`
 	} `json:"change_log"`
 	Children []Post   `json:"children"`
 	Config   struct{} `json:"config"`
-	Created  string   `json:"created"`
+	Created  Time     `json:"created"`
 	Data     struct {
 		EmbedLinks []interface{} `json:"embed_links"`
 	} `json:"data"`
@@ -641,7 +792,7 @@ type Post struct {
 	History          []struct {
 		Anon    string `json:"anon"`
 		Content string `json:"content"`
-		Created string `json:"created"`
+		Created Time   `json:"created"`
 		Subject string `json:"subject"`
 		UID     string `json:"uid"`
 	} `json:"history"`
@@ -659,6 +810,7 @@ type Post struct {
 	RequestInstructorMe bool          `json:"request_instructor_me"`
 	SEdits              []interface{} `json:"s_edits"`
 	Status              string        `json:"status"`
+	Subject             string        `json:"subject"`
 	T                   int           `json:"t"`
 	TagGood             []struct {
 		Admin      bool        `json:"admin"`
@@ -672,6 +824,7 @@ type Post struct {
 	TagGoodArr  []string      `json:"tag_good_arr"`
 	Tags        []string      `json:"tags"`
 	Type        string        `json:"type"`
+	UID         string        `json:"uid"`
 	UniqueViews int           `json:"unique_views"`
 	UpvoteIds   []interface{} `json:"upvote_ids"`
 }
@@ -689,9 +842,14 @@ type contentGetReq struct {
 
 // Content returns a piece of content for a class.
 func (c *Client) Content(classID, contentID string) (Post, error) {
+	return c.ContentContext(context.Background(), classID, contentID)
+}
+
+// ContentContext is like Content but aborts when ctx is done.
+func (c *Client) ContentContext(ctx context.Context, classID, contentID string) (Post, error) {
 	req := contentGetReq{Nid: classID, Cid: contentID}
 	var resp contentGetResponse
-	if err := c.MakeAPIReq("content.get", req, &resp); err != nil {
+	if err := c.MakeAPIReqContext(ctx, "content.get", req, &resp); err != nil {
 		return Post{}, err
 	}
 	return resp.Result, nil
@@ -699,5 +857,5 @@ func (c *Client) Content(classID, contentID string) (Post, error) {
 
 // Cookies returns the cookies for the Piazza client.
 func (c *Client) Cookies() []*http.Cookie {
-	return c.bow.SiteCookies()
+	return c.http.Jar.Cookies(c.base)
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"path/filepath"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/archive"
+)
+
+// archiveCmd saves classes with the archive package. Without -class every
+// class the user is in is archived to a directory named after its ID.
+func archiveCmd(ctx context.Context, c *piazza.Client, args []string) error {
+	fs := flag.NewFlagSet("archive", flag.ExitOnError)
+	class := fs.String("class", "", "ID of the class to archive; all classes if unset")
+	out := fs.String("out", "archive", "directory to write the archive to")
+	concurrency := fs.Int("concurrency", archive.DefaultConcurrency, "number of posts to fetch at once")
+	force := fs.Bool("force", false, "refetch posts and files that were already archived")
+	links := fs.Bool("links", false, "also download resources that link outside of Piazza")
+	fs.Parse(args)
+
+	opts := archive.Options{
+		Concurrency:   *concurrency,
+		Force:         *force,
+		ExternalLinks: *links,
+		Logf:          log.Printf,
+	}
+	if len(*class) > 0 {
+		_, err := archive.Write(ctx, c, *class, *out, opts)
+		return err
+	}
+
+	status, err := c.UserStatusContext(ctx)
+	if err != nil {
+		return err
+	}
+	for _, n := range status.Result.Networks {
+		if _, err := archive.Write(ctx, c, n.ID, filepath.Join(*out, n.ID), opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/digest"
+	"github.com/pkg/errors"
+)
+
+// digestState is what the digest command remembers between runs.
+type digestState struct {
+	LastRun time.Time `json:"last_run"`
+}
+
+// digestCmd emails the posts that changed in every class since the last run,
+// or writes the email to a .eml file with -eml.
+func digestCmd(ctx context.Context, c *piazza.Client, args []string) error {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	stateFile := fs.String("state", "digest-state.json", "file to remember the last run in")
+	window := fs.Duration("since", 24*time.Hour, "how far back the first run looks")
+	from := fs.String("from", "", "address to send the digest from")
+	to := fs.String("to", "", "comma separated addresses to send the digest to")
+	smtpAddr := fs.String("smtp", "", "host:port of the SMTP server to send through")
+	smtpUser := fs.String("smtp-user", "", "SMTP username")
+	smtpPassword := fs.String("smtp-password", "", "SMTP password")
+	startTLS := fs.Bool("starttls", false, "fail rather than send in the clear if the server lacks STARTTLS")
+	eml := fs.String("eml", "", "directory to write the digest to as a .eml file instead of sending it")
+	fs.Parse(args)
+
+	var recipients []string
+	for _, addr := range strings.Split(*to, ",") {
+		if addr = strings.TrimSpace(addr); len(addr) > 0 {
+			recipients = append(recipients, addr)
+		}
+	}
+	if len(*eml) == 0 && (len(*smtpAddr) == 0 || len(*from) == 0 || len(recipients) == 0) {
+		return errors.New("digest: -smtp, -from and -to are needed to send, or -eml to write a file")
+	}
+
+	state := digestState{LastRun: time.Now().Add(-*window)}
+	if buf, err := ioutil.ReadFile(*stateFile); err == nil {
+		if err := json.Unmarshal(buf, &state); err != nil {
+			return errors.Wrapf(err, "reading %q", *stateFile)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	d, err := digest.Gather(ctx, c, state.LastRun)
+	if err != nil {
+		return err
+	}
+	if d.Len() == 0 {
+		log.Printf("nothing changed since %s", state.LastRun.Local().Format(time.RFC1123))
+	} else {
+		msg, err := digest.Message(d, digest.Header{From: *from, To: recipients})
+		if err != nil {
+			return err
+		}
+		if len(*eml) > 0 {
+			file, err := digest.WriteEML(*eml, d.Until, msg)
+			if err != nil {
+				return err
+			}
+			log.Printf("wrote %d posts to %s", d.Len(), file)
+		} else {
+			// The SMTP envelope takes bare addresses, without display
+			// names.
+			envelope, err := bareAddresses(append([]string{*from}, recipients...))
+			if err != nil {
+				return err
+			}
+			err = digest.SMTP{
+				Addr:     *smtpAddr,
+				Username: *smtpUser,
+				Password: *smtpPassword,
+				StartTLS: *startTLS,
+			}.Send(ctx, envelope[0], envelope[1:], msg)
+			if err != nil {
+				return err
+			}
+			log.Printf("sent %d posts to %s", d.Len(), strings.Join(recipients, ", "))
+		}
+	}
+
+	// Only remember the run once the digest is out, so a failed one is
+	// retried.
+	buf, err := json.MarshalIndent(digestState{LastRun: d.Until}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(*stateFile, buf, 0600)
+}
+
+// bareAddresses returns the addresses of addrs without their display names.
+func bareAddresses(addrs []string) ([]string, error) {
+	var bare []string
+	for _, addr := range addrs {
+		a, err := mail.ParseAddress(addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "address %q", addr)
+		}
+		bare = append(bare, a.Address)
+	}
+	return bare, nil
+}
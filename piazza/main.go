@@ -1,25 +1,215 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
 
 	piazza "github.com/d4l3k/piazza-api"
+	"github.com/pkg/errors"
 )
 
 var (
 	username = flag.String("username", "", "Piazza username")
 	password = flag.String("password", "", "Piazza password")
+	session  = flag.String("session", "", "file to reuse the login session from and save it to")
+
+	class    = flag.String("class", "", "ID of the class to change the email preferences of; all classes if unset")
+	newFreq  = flag.String("new", "", "how often to get emails about new posts: real-time, digest or off")
+	updates  = flag.String("updates", "", "how often to get emails about posts you follow: real-time, digest or off")
+	follow   = flag.String("auto-follow", "", "whether to follow every new post: true or false; unchanged if unset")
+	throttle = flag.Int("throttle", -1, "email throttle to set; unchanged if negative")
+	dryRun   = flag.Bool("dry-run", false, "show how the email preferences would change without changing them")
 )
 
+// client returns a logged in client, reusing the saved session if there is a
+// valid one.
+func client(ctx context.Context) (*piazza.Client, error) {
+	if len(*session) > 0 {
+		c, err := piazza.RestoreClientFile(*session, piazza.ClientOptions{})
+		if err == nil {
+			if err = c.CheckSession(ctx); err == nil {
+				return c, nil
+			}
+		}
+		log.Printf("not reusing session %q: %v", *session, err)
+	}
+
+	c, err := piazza.MakeClientContext(ctx, *username, *password, piazza.ClientOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(*session) > 0 {
+		if _, err := c.UserStatusContext(ctx); err != nil {
+			return nil, err
+		}
+		if err := c.SaveSessionFile(*session); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// commands are the subcommands, which get the arguments after their name.
+// Without one, the user's email preferences are changed.
+var commands = map[string]func(ctx context.Context, c *piazza.Client, args []string) error{
+	"archive": archiveCmd,
+	"digest":  digestCmd,
+	"sqlite":  sqliteCmd,
+	"webhook": webhookCmd,
+}
+
+// localCommands are subcommands that work on files and don't log in.
+var localCommands = map[string]func(ctx context.Context, args []string) error{
+	"site": siteCmd,
+}
+
+// setEmailPrefs changes email preferences as the flags say, printing how
+// they change. Without -new, -updates, -auto-follow or -throttle, emails about
+// new posts are turned off.
+func setEmailPrefs(ctx context.Context, c *piazza.Client, args []string) error {
+	var u piazza.EmailPrefUpdate
+	var err error
+	if len(*newFreq) > 0 {
+		if u.New, err = piazza.ParseEmailFrequency(*newFreq); err != nil {
+			return err
+		}
+	}
+	if len(*updates) > 0 {
+		if u.Updates, err = piazza.ParseEmailFrequency(*updates); err != nil {
+			return err
+		}
+	}
+	if len(*follow) > 0 {
+		on, err := strconv.ParseBool(*follow)
+		if err != nil {
+			return errors.Errorf("-auto-follow %q isn't true or false", *follow)
+		}
+		u.AutoFollow = &on
+	}
+	if *throttle >= 0 {
+		u.Throttle = throttle
+	}
+	if len(u.New) == 0 && len(u.Updates) == 0 && u.AutoFollow == nil && u.Throttle == nil {
+		u.New = piazza.EmailNone
+	}
+
+	status, err := c.UserStatusContext(ctx)
+	if err != nil {
+		return err
+	}
+	names := map[string]string{}
+	for _, n := range status.Result.Networks {
+		names[n.ID] = n.Name
+	}
+	prefs, err := c.EmailPrefs(ctx)
+	if err != nil {
+		return err
+	}
+	next, err := prefs.With(*class, u)
+	if err != nil {
+		return err
+	}
+	changes := emailPrefsDiff(prefs, next, names)
+	if len(changes) == 0 {
+		fmt.Println("Email preferences are already set.")
+		return nil
+	}
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if *dryRun {
+		return nil
+	}
+	_, err = c.SetEmailPrefs(ctx, *class, u)
+	return err
+}
+
+// emailPrefsDiff describes what changes from prefs to next, a line per setting
+// of each class.
+func emailPrefsDiff(prefs, next piazza.EmailPrefs, names map[string]string) []string {
+	var ids []string
+	for id := range next {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var changes []string
+	for _, id := range ids {
+		old, pref := prefs[id], next[id]
+		class := id
+		if name := names[id]; len(name) > 0 {
+			class = fmt.Sprintf("%s (%s)", id, name)
+		}
+		for _, setting := range []struct {
+			name     string
+			old, new interface{}
+		}{
+			{"new", old.New, pref.New},
+			{"updates", old.Updates, pref.Updates},
+			{"no_events", old.NoEvents, pref.NoEvents},
+			{"auto_follow", old.AutoFollow, pref.AutoFollow},
+			{"throttle", old.Throttle, pref.Throttle},
+		} {
+			if !reflect.DeepEqual(setting.old, setting.new) {
+				changes = append(changes, fmt.Sprintf("%s: %s %v -> %v", class, setting.name, setting.old, setting.new))
+			}
+		}
+	}
+	return changes
+}
+
 func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [command [command flags]]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Without a command, changes email preferences as -class, -new, -updates, -auto-follow\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "and -throttle say, or opts out of emails about new posts in every class. Commands:\n")
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		for name := range localCommands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(flag.CommandLine.Output(), "  %s\n", name)
+		}
+		fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	c, err := piazza.MakeClient(*username, *password)
+	var args []string
+	if flag.NArg() > 1 {
+		args = flag.Args()[1:]
+	}
+	ctx := context.Background()
+	if local, ok := localCommands[flag.Arg(0)]; ok {
+		if err := local(ctx, args); err != nil {
+			log.Fatalf("%+v", err)
+		}
+		return
+	}
+
+	cmd := setEmailPrefs
+	if flag.NArg() > 0 {
+		var ok bool
+		if cmd, ok = commands[flag.Arg(0)]; !ok {
+			flag.Usage()
+			os.Exit(2)
+		}
+	}
+
+	c, err := client(ctx)
 	if err != nil {
 		log.Fatalf("%+v", err)
 	}
-	if err := c.OptOutOfEmails(); err != nil {
+	if err := cmd(ctx, c, args); err != nil {
 		log.Fatalf("%+v", err)
 	}
 }
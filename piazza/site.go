@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/d4l3k/piazza-api/site"
+)
+
+// siteCmd renders an archive written by the archive command as a static site.
+func siteCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("site", flag.ExitOnError)
+	in := fs.String("archive", "archive", "directory of the class archive to render")
+	out := fs.String("out", "site", "directory to write the site to")
+	mathJax := fs.String("mathjax", site.DefaultMathJaxURL, "URL of the MathJax script that typesets LaTeX")
+	deleted := fs.Bool("deleted", false, "include posts that were deleted from Piazza after they were archived")
+	baseURL := fs.String("base-url", "", "site relative links point at; defaults to the one the archive was made from")
+	fs.Parse(args)
+
+	return site.Generate(*in, *out, site.Options{
+		MathJaxURL: *mathJax,
+		Deleted:    *deleted,
+		BaseURL:    *baseURL,
+		Logf:       log.Printf,
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/store/sqlite"
+)
+
+// sqliteCmd loads classes into a SQLite database with the store/sqlite
+// package. Without -class every class the user is in is loaded.
+func sqliteCmd(ctx context.Context, c *piazza.Client, args []string) error {
+	fs := flag.NewFlagSet("sqlite", flag.ExitOnError)
+	class := fs.String("class", "", "ID of the class to load; all classes if unset")
+	db := fs.String("db", "piazza.db", "SQLite database to load the classes into")
+	concurrency := fs.Int("concurrency", piazza.DefaultSyncConcurrency, "number of posts to fetch at once")
+	force := fs.Bool("force", false, "refetch posts that were already loaded")
+	fs.Parse(args)
+
+	s, err := sqlite.Open(ctx, *db)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	networks := []string{*class}
+	if len(*class) == 0 {
+		status, err := c.UserStatusContext(ctx)
+		if err != nil {
+			return err
+		}
+		networks = nil
+		for _, n := range status.Result.Networks {
+			networks = append(networks, n.ID)
+		}
+	}
+	opts := piazza.SyncOptions{Concurrency: *concurrency, Force: *force}
+	for _, nid := range networks {
+		summary, err := s.Sync(ctx, c, nid, opts)
+		if err != nil {
+			return err
+		}
+		log.Printf("%s: %d new, %d updated, %d deleted and %d unchanged posts",
+			nid, len(summary.New), len(summary.Updated), len(summary.Deleted), summary.Unchanged)
+	}
+	return nil
+}
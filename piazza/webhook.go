@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/watch"
+	"github.com/d4l3k/piazza-api/webhook"
+	"github.com/pkg/errors"
+)
+
+// webhookCmd watches classes and posts their events to the endpoints in a
+// webhook config until interrupted.
+func webhookCmd(ctx context.Context, c *piazza.Client, args []string) error {
+	fs := flag.NewFlagSet("webhook", flag.ExitOnError)
+	config := fs.String("config", "webhooks.json", "JSON file of the endpoints to deliver to")
+	cursors := fs.String("cursors", "webhook-cursors.json", "file to keep what's been seen of each class in")
+	deadLetter := fs.String("dead-letter", "webhook-dead-letter.jsonl", "file to append deliveries that keep failing to")
+	interval := fs.Duration("interval", watch.DefaultInterval, "time between polls of each class")
+	attempts := fs.Int("attempts", webhook.DefaultAttempts, "times to try each delivery")
+	backoff := fs.Duration("backoff", webhook.DefaultBackoff, "wait after the first failed delivery, doubling after each one")
+	fs.Parse(args)
+
+	conf, err := webhook.ReadConfig(*config)
+	if err != nil {
+		return err
+	}
+	networks, ok := conf.Networks()
+	if !ok {
+		status, err := c.UserStatusContext(ctx)
+		if err != nil {
+			return err
+		}
+		for _, n := range status.Result.Networks {
+			networks = append(networks, n.ID)
+		}
+	}
+	if len(networks) == 0 {
+		return errors.New("webhook: no classes to watch")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+	go func() {
+		select {
+		case <-sigs:
+			log.Printf("shutting down")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	d := webhook.New(conf.Endpoints, webhook.Options{
+		Attempts:   *attempts,
+		Backoff:    *backoff,
+		BaseURL:    c.BaseURL(),
+		DeadLetter: *deadLetter,
+		Logf:       log.Printf,
+	})
+	w := watch.New(c, networks, watch.Options{
+		Interval: *interval,
+		Jitter:   *interval / 10,
+		Cursors:  watch.NewFileCursors(*cursors),
+		Handler: func(e watch.Event) {
+			if err := d.Dispatch(ctx, e); err != nil && ctx.Err() == nil {
+				log.Printf("dispatching %s: %v", e, err)
+			}
+		},
+		Logf: log.Printf,
+	})
+	log.Printf("watching %d classes for %d endpoints", len(networks), len(conf.Endpoints))
+	err = w.Run(ctx)
+
+	// Give queued deliveries a little while to go out; the rest are
+	// dead-lettered.
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer closeCancel()
+	d.Close(closeCtx)
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
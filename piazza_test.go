@@ -0,0 +1,126 @@
+package piazza
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientOptions(t *testing.T) {
+	var gotPath, gotMethod, gotUA, resourceUA string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/logic/api" {
+			resourceUA = r.Header.Get("User-Agent")
+			return
+		}
+		gotPath = r.URL.Path
+		gotMethod = r.URL.Query().Get("method")
+		gotUA = r.Header.Get("User-Agent")
+		w.Write([]byte(`{"aid":"abc","error":null,"result":{"networks":[{"id":"n1"}]}}`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ClientOptions{
+		BaseURL:    ts.URL + "/",
+		HTTPClient: ts.Client(),
+		UserAgent:  "piazza-test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UserStatus(); err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/logic/api" || gotMethod != "user.status" {
+		t.Errorf("request went to %q method %q", gotPath, gotMethod)
+	}
+	if gotUA != "piazza-test" {
+		t.Errorf("User-Agent = %q; not %q", gotUA, "piazza-test")
+	}
+	if c.aid != "abc" {
+		t.Errorf("aid = %q; not %q", c.aid, "abc")
+	}
+
+	want := ts.URL + "/ubc.ca/winterterm12016/cpsc317/home"
+	n := Network{SchoolExt: "ubc.ca", Term: "Winter Term 1 2016", ShortNumber: "cpsc317"}
+	if got := c.ResourceURL(n); got != want {
+		t.Errorf("c.ResourceURL() = %q; not %q", got, want)
+	}
+
+	// Pages the HTMLWrapper fetches outside the API identify the same way.
+	w := c.HTMLWrapper()
+	for _, uri := range []string{"piazza://", "piazza://n1"} {
+		if _, err := w.Get(uri); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if resourceUA != "piazza-test" {
+		t.Errorf("HTMLWrapper User-Agent = %q; not %q", resourceUA, "piazza-test")
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	c, err := NewClient(ClientOptions{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.UserStatusContext(ctx); err == nil {
+		t.Errorf("UserStatusContext() = nil; expected an error")
+	}
+	if err := c.LoginContext(ctx, "user", "pass"); err != context.DeadlineExceeded {
+		t.Errorf("LoginContext() = %v; not %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("method") == "content.get" {
+			w.Write([]byte(`{"error":"Too many requests, slow down","result":null}`))
+			return
+		}
+		http.Error(w, "busy", http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ClientOptions{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UserStatus(); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("UserStatus() = %v; not %v", err, ErrRateLimited)
+	}
+	if _, err := c.Content("n", "c"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Content() = %v; not %v", err, ErrRateLimited)
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	for msg, want := range map[string]error{
+		"Not logged in":                        ErrNotLoggedIn,
+		"Please log in to continue":            ErrNotLoggedIn,
+		"You must be logged in to do that":     ErrNotLoggedIn,
+		"Session expired for the upload":       nil,
+		"Login required to view this folder":   nil,
+		"Could not find requested content":     ErrNotFound,
+		"Method not found":                     ErrUnknownMethod,
+		"Too many requests, slow down":         ErrRateLimited,
+		"You don't have permission to do that": ErrPermissionDenied,
+	} {
+		if got := errorKind(msg); got != want {
+			t.Errorf("errorKind(%q) = %v; not %v", msg, got, want)
+		}
+	}
+}
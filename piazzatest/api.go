@@ -0,0 +1,406 @@
+package piazzatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	piazza "github.com/d4l3k/piazza-api"
+)
+
+// apiError is returned by method handlers and ends up in the "error" field of
+// the response envelope.
+type apiError string
+
+func (e apiError) Error() string { return string(e) }
+
+// Error messages returned by the fake, modelled on the ones Piazza sends.
+const (
+	errNotLoggedIn   apiError = "Not logged in"
+	errNotFound      apiError = "Could not find requested content"
+	errNoPermission  apiError = "You do not have permission to do that"
+	errUnknownMethod apiError = "Method not found"
+)
+
+type apiHandler func(s *Server, u *User, params json.RawMessage) (interface{}, error)
+
+var apiHandlers = map[string]apiHandler{
+	"user.status":           (*Server).userStatus,
+	"user.update":           (*Server).userUpdate,
+	"network.get_my_feed":   (*Server).getMyFeed,
+	"network.filter_feed":   (*Server).filterFeed,
+	"network.search":        (*Server).search,
+	"network.get_users":     (*Server).getUsers,
+	"network.get_all_users": (*Server).getAllUsers,
+	"content.get":           (*Server).contentGet,
+	"content.create":        (*Server).contentCreate,
+	"content.answer":        (*Server).contentAnswer,
+	"content.update":        (*Server).contentUpdate,
+	"content.delete":        (*Server).contentDelete,
+	"content.pin":           (*Server).contentPin,
+	"content.mark_resolved": (*Server).contentMarkResolved,
+	"content.mark_good":     (*Server).contentMarkGood,
+	"content.duplicate":     (*Server).contentDuplicate,
+}
+
+type apiResponse struct {
+	Aid    string          `json:"aid"`
+	Error  interface{}     `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	unavailable := s.unavailable[req.Method]
+	failure := s.failures[req.Method]
+	hook := s.hooks[req.Method]
+	s.mu.Unlock()
+	if unavailable {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if hook != nil {
+		hook()
+	}
+
+	resp := apiResponse{Aid: r.URL.Query().Get("aid")}
+	var err error
+	if u := s.sessionUser(r); u == nil {
+		err = errNotLoggedIn
+	} else if len(failure) > 0 {
+		err = apiError(failure)
+	} else if h, ok := apiHandlers[req.Method]; !ok {
+		err = errUnknownMethod
+	} else {
+		s.mu.Lock()
+		var result interface{}
+		if result, err = h(s, u, req.Params); err == nil {
+			// Results may point into the model, so encode them under the lock.
+			resp.Result, err = json.Marshal(result)
+		}
+		s.mu.Unlock()
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return apiError(fmt.Sprintf("bad params: %s", err))
+	}
+	return nil
+}
+
+func (s *Server) userStatus(u *User, params json.RawMessage) (interface{}, error) {
+	var networks []piazza.Network
+	for _, n := range s.networks {
+		if _, ok := u.Roles[n.ID]; ok {
+			networks = append(networks, n.Network)
+		}
+	}
+	return map[string]interface{}{
+		"id":       u.ID,
+		"name":     u.Name,
+		"email":    u.Email,
+		"emails":   []string{u.Email},
+		"networks": networks,
+		"config": map[string]interface{}{
+			"email_prefs": u.EmailPrefs,
+			"roles":       u.Roles,
+		},
+	}, nil
+}
+
+func (s *Server) userUpdate(u *User, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		EmailPrefs piazza.EmailPrefs `json:"email_prefs"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	for k, v := range req.EmailPrefs {
+		u.EmailPrefs[k] = v
+	}
+	return "OK", nil
+}
+
+// userNetwork returns the network if the user is enrolled in it.
+func (s *Server) userNetwork(u *User, nid string) (*network, error) {
+	n := s.network(nid)
+	if n == nil {
+		return nil, errNotFound
+	}
+	if _, ok := u.Roles[nid]; !ok {
+		return nil, errNoPermission
+	}
+	return n, nil
+}
+
+var tagRegexp = regexp.MustCompile(`<[^>]*>`)
+
+// feedItem builds the summary of a post that feeds report.
+func (s *Server) feedItem(n *network, p *piazza.Post) map[string]interface{} {
+	var subject, snippet string
+	updated := p.Created
+	if len(p.History) > 0 {
+		h := p.History[0]
+		subject = h.Subject
+		snippet = tagRegexp.ReplaceAllString(h.Content, "")
+		if len(snippet) > 120 {
+			snippet = snippet[:120]
+		}
+		if h.Created.After(updated.Time) {
+			updated = h.Created
+		}
+	}
+	// Edits change modified; replies only change updated.
+	modified := updated
+	log := []map[string]interface{}{}
+	for _, c := range p.ChangeLog {
+		log = append(log, map[string]interface{}{"n": c.Type, "t": c.When, "u": c.UID})
+		if c.When.After(updated.Time) {
+			updated = c.When
+		}
+	}
+	pin := 0
+	if p.BucketName == "Pinned" {
+		pin = 1
+	}
+	folders := p.Folders
+	if folders == nil {
+		folders = []string{}
+	}
+	tags := append([]string{}, p.Tags...)
+	if s.byInstructor(n, p) {
+		tags = append(tags, "instructor-"+p.Type)
+	}
+	noAnswer := 0
+	if p.Type == "question" && !answered(p) {
+		noAnswer = 1
+	}
+	return map[string]interface{}{
+		"id":                 p.ID,
+		"nr":                 p.Nr,
+		"type":               p.Type,
+		"status":             p.Status,
+		"subject":            subject,
+		"content_snipet":     snippet,
+		"folders":            folders,
+		"tags":               tags,
+		"log":                log,
+		"main_version":       len(p.History),
+		"created":            p.Created,
+		"modified":           modified,
+		"updated":            updated,
+		"no_answer":          noAnswer,
+		"no_answer_followup": p.NoAnswerFollowup,
+		"num_favorites":      p.NumFavorites,
+		"unique_views":       p.UniqueViews,
+		"gd":                 len(p.TagGood),
+		"pin":                pin,
+	}
+}
+
+type feedReq struct {
+	Nid    string `json:"nid"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Sort   string `json:"sort"`
+
+	// Filters, only used by network.filter_feed.
+	Folder       int    `json:"folder"`
+	FilterFolder string `json:"filter_folder"`
+	Unread       int    `json:"unread"`
+	Unresolved   int    `json:"unresolved"`
+	Following    int    `json:"following"`
+	Instructor   int    `json:"instructor"`
+}
+
+// instructorRoles are the roles whose posts count as instructor posts.
+var instructorRoles = map[string]bool{
+	"instructor": true,
+	"professor":  true,
+	"ta":         true,
+	"admin":      true,
+}
+
+// keep reports whether a post passes the request's filters. The fake has no
+// notion of read posts, so everything is unread.
+func (s *Server) keep(req feedReq, n *network, u *User, p *piazza.Post) bool {
+	if req.Folder != 0 {
+		found := false
+		for _, f := range p.Folders {
+			found = found || f == req.FilterFolder
+		}
+		if !found {
+			return false
+		}
+	}
+	if req.Unresolved != 0 {
+		if p.NoAnswerFollowup == 0 && (answered(p) || p.Type != "question") {
+			return false
+		}
+	}
+	if req.Following != 0 {
+		found := false
+		for _, c := range p.ChangeLog {
+			found = found || c.UID == u.ID
+		}
+		if !found {
+			return false
+		}
+	}
+	if req.Instructor != 0 && !s.byInstructor(n, p) {
+		return false
+	}
+	return true
+}
+
+// answered reports whether a post has a student or instructor answer.
+func answered(p *piazza.Post) bool {
+	_, ok := p.Child(piazza.StudentAnswer)
+	if !ok {
+		_, ok = p.Child(piazza.InstructorAnswer)
+	}
+	return ok
+}
+
+// byInstructor reports whether whoever created a post is an instructor.
+func (s *Server) byInstructor(n *network, p *piazza.Post) bool {
+	if len(p.ChangeLog) == 0 {
+		return false
+	}
+	author, ok := s.users[p.ChangeLog[0].UID]
+	return ok && instructorRoles[author.Roles[n.ID]]
+}
+
+func (s *Server) feed(u *User, params json.RawMessage, filter bool) (interface{}, error) {
+	var req feedReq
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	n, err := s.userNetwork(u, req.Nid)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []map[string]interface{}{}
+	for _, p := range n.posts {
+		if filter && !s.keep(req, n, u, p) {
+			continue
+		}
+		items = append(items, s.feedItem(n, p))
+	}
+	key := "updated"
+	if req.Sort == "created" {
+		key = "created"
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i][key].(piazza.Time).After(items[j][key].(piazza.Time).Time)
+	})
+
+	more := false
+	if req.Offset > len(items) {
+		req.Offset = len(items)
+	}
+	items = items[req.Offset:]
+	if s.feedPageLimit > 0 && (req.Limit <= 0 || req.Limit > s.feedPageLimit) {
+		req.Limit = s.feedPageLimit
+	}
+	if req.Limit > 0 && req.Limit < len(items) {
+		items = items[:req.Limit]
+		more = true
+	}
+	return map[string]interface{}{
+		"feed": items,
+		"more": more,
+		"sort": req.Sort,
+	}, nil
+}
+
+func (s *Server) getMyFeed(u *User, params json.RawMessage) (interface{}, error) {
+	return s.feed(u, params, false)
+}
+
+func (s *Server) filterFeed(u *User, params json.RawMessage) (interface{}, error) {
+	return s.feed(u, params, true)
+}
+
+func (s *Server) contentGet(u *User, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		Cid string `json:"cid"`
+		Nid string `json:"nid"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	n, err := s.userNetwork(u, req.Nid)
+	if err != nil {
+		return nil, err
+	}
+	// Replies can be fetched by their own IDs too.
+	p, _ := n.find(req.Cid)
+	if p == nil {
+		return nil, errNotFound
+	}
+	return p, nil
+}
+
+// search matches the query as a substring of the subject or content of each
+// post's latest revision.
+func (s *Server) search(u *User, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		Nid   string `json:"nid"`
+		Query string `json:"query"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	n, err := s.userNetwork(u, req.Nid)
+	if err != nil {
+		return nil, err
+	}
+
+	query := strings.ToLower(req.Query)
+	hits := []map[string]interface{}{}
+	for _, p := range n.posts {
+		if len(p.History) == 0 || len(query) == 0 {
+			continue
+		}
+		h := p.History[0]
+		score := 2*strings.Count(strings.ToLower(h.Subject), query) +
+			strings.Count(strings.ToLower(h.Content), query)
+		if score == 0 {
+			continue
+		}
+		item := s.feedItem(n, p)
+		item["score"] = float64(score)
+		hits = append(hits, item)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i]["score"].(float64) > hits[j]["score"].(float64)
+	})
+	return hits, nil
+}
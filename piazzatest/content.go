@@ -0,0 +1,195 @@
+package piazzatest
+
+import (
+	"encoding/json"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+)
+
+// now returns the current time to the precision Piazza keeps.
+func now() piazza.Time {
+	return piazza.Time{Time: time.Now().UTC().Truncate(time.Second)}
+}
+
+// convert copies between types with the same JSON form. It's used to build
+// piazza types without spelling out their nested anonymous structs.
+func convert(from, to interface{}) {
+	buf, err := json.Marshal(from)
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(buf, to); err != nil {
+		panic(err)
+	}
+}
+
+// revision returns a history entry written by u.
+func revision(u *User, subject, content, anon string) map[string]interface{} {
+	return map[string]interface{}{
+		"anon":    anon,
+		"content": content,
+		"created": now(),
+		"subject": subject,
+		"uid":     u.ID,
+	}
+}
+
+// logEntry returns a change log entry recording an action by u.
+func logEntry(u *User, typ, anon string) map[string]interface{} {
+	return map[string]interface{}{
+		"anon": anon,
+		"type": typ,
+		"uid":  u.ID,
+		"when": now(),
+	}
+}
+
+func (s *Server) contentCreate(u *User, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		Nid       string   `json:"nid"`
+		Cid       string   `json:"cid"`
+		Type      string   `json:"type"`
+		Subject   string   `json:"subject"`
+		Content   string   `json:"content"`
+		Folders   []string `json:"folders"`
+		Anonymous string   `json:"anonymous"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	n, err := s.userNetwork(u, req.Nid)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Cid) > 0 {
+		return s.createReply(n, u, req.Cid, req.Type, req.Subject, req.Anonymous)
+	}
+
+	var p piazza.Post
+	convert(map[string]interface{}{
+		"id":         s.newID(),
+		"nr":         n.nextNr(),
+		"type":       req.Type,
+		"status":     "active",
+		"folders":    req.Folders,
+		"created":    now(),
+		"history":    []interface{}{revision(u, req.Subject, req.Content, req.Anonymous)},
+		"change_log": []interface{}{logEntry(u, "create", req.Anonymous)},
+	}, &p)
+	n.posts = append(n.posts, &p)
+	return p, nil
+}
+
+// find returns the post or reply with the given ID, and the top level post it
+// belongs to.
+func (n *network) find(cid string) (p, root *piazza.Post) {
+	if p := n.post(cid); p != nil {
+		return p, p
+	}
+	for _, root := range n.posts {
+		if p := findChild(root, cid); p != nil {
+			return p, root
+		}
+	}
+	return nil, nil
+}
+
+func findChild(p *piazza.Post, cid string) *piazza.Post {
+	for i := range p.Children {
+		child := &p.Children[i]
+		if child.ID == cid {
+			return child
+		}
+		if found := findChild(child, cid); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// createReply adds a followup to a post or feedback to a followup.
+func (s *Server) createReply(n *network, u *User, cid, typ, text, anon string) (interface{}, error) {
+	parent, root := n.find(cid)
+	if parent == nil {
+		return nil, errNotFound
+	}
+	switch {
+	case typ == "followup" && parent == root:
+	case typ == "feedback" && parent.Type == "followup":
+	default:
+		return nil, apiError("Cannot reply with a " + typ + " to a " + parent.Type)
+	}
+
+	var child piazza.Post
+	convert(map[string]interface{}{
+		"id":      s.newID(),
+		"type":    typ,
+		"created": now(),
+		"subject": text,
+		"uid":     u.ID,
+		"anon":    anon,
+	}, &child)
+	if typ == "followup" {
+		// New followups start out unresolved.
+		child.NoAnswer = 1
+		root.NoAnswerFollowup++
+	}
+	parent.Children = append(parent.Children, child)
+	s.appendLog(root, u, typ, anon)
+	return child, nil
+}
+
+// appendLog records an action in a post's change log.
+func (s *Server) appendLog(p *piazza.Post, u *User, typ, anon string) {
+	var entries []interface{}
+	convert(p.ChangeLog, &entries)
+	entries = append(entries, logEntry(u, typ, anon))
+	convert(entries, &p.ChangeLog)
+}
+
+func (s *Server) contentAnswer(u *User, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		Nid       string `json:"nid"`
+		Cid       string `json:"cid"`
+		Type      string `json:"type"`
+		Content   string `json:"content"`
+		Revision  int    `json:"revision"`
+		Anonymous string `json:"anonymous"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	n, err := s.userNetwork(u, req.Nid)
+	if err != nil {
+		return nil, err
+	}
+	p := n.post(req.Cid)
+	if p == nil {
+		return nil, errNotFound
+	}
+	if req.Type != "s_answer" && req.Type != "i_answer" {
+		return nil, apiError("Unknown answer type " + req.Type)
+	}
+
+	var answer *piazza.Post
+	for i := range p.Children {
+		if p.Children[i].Type == req.Type {
+			answer = &p.Children[i]
+		}
+	}
+	if answer == nil {
+		p.Children = append(p.Children, piazza.Post{ID: s.newID(), Type: req.Type, Created: now()})
+		answer = &p.Children[len(p.Children)-1]
+	}
+	if req.Revision != len(answer.History) {
+		return nil, apiError("The answer was edited by someone else; reload to see their changes")
+	}
+
+	var history []interface{}
+	convert(answer.History, &history)
+	history = append([]interface{}{revision(u, "", req.Content, req.Anonymous)}, history...)
+	convert(history, &answer.History)
+	s.appendLog(p, u, req.Type, req.Anonymous)
+	return *answer, nil
+}
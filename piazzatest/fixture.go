@@ -0,0 +1,116 @@
+package piazzatest
+
+import (
+	"encoding/json"
+
+	piazza "github.com/d4l3k/piazza-api"
+)
+
+// The accounts of a Fixture.
+const (
+	StudentID          = "u1"
+	StudentEmail       = "student@example.com"
+	StudentPassword    = "hunter2"
+	InstructorID       = "i1"
+	InstructorEmail    = "prof@example.com"
+	InstructorPassword = "hunter3"
+)
+
+// TB is the part of testing.TB a Fixture uses, so this package doesn't import
+// testing into every program that uses it.
+type TB interface {
+	Helper()
+	Fatal(args ...interface{})
+	Cleanup(func())
+}
+
+// Fixture is a fake with a student and an instructor account, which tests
+// seed with the classes and posts they need:
+//
+//	f, student, instructor := piazzatest.NewClass(t, `{"id": "n1", "name": "CPSC 317"}`,
+//		`{"id": "p1", "type": "note", "history": [{"subject": "Hi"}]}`)
+type Fixture struct {
+	*Server
+	t TB
+}
+
+// NewFixture starts a fake with the accounts of a Fixture and no classes. It's
+// closed when the test ends.
+func NewFixture(t TB) *Fixture {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	s.AddUser(User{ID: StudentID, Email: StudentEmail, Password: StudentPassword})
+	s.AddUser(User{ID: InstructorID, Email: InstructorEmail, Password: InstructorPassword})
+	return &Fixture{Server: s, t: t}
+}
+
+// NewClass starts a Fixture with one class, added as by AddClass, and returns
+// it along with clients logged in as the student and the instructor.
+func NewClass(t TB, network string, posts ...string) (f *Fixture, student, instructor *piazza.Client) {
+	t.Helper()
+	f = NewFixture(t)
+	f.AddClass(network, posts...)
+	return f, f.Student(), f.Instructor()
+}
+
+// AddClass adds the class network, the JSON of a piazza.Network, with the
+// student and instructor enrolled as such, and the posts, each the JSON of a
+// piazza.Post. It returns the class ID.
+func (f *Fixture) AddClass(network string, posts ...string) string {
+	f.t.Helper()
+	var n piazza.Network
+	f.Unmarshal(network, &n)
+	nid := f.AddNetwork(n, StudentID)
+
+	// AddNetwork only enrolls students, so the instructor gets their role by
+	// re-adding the account.
+	u, _ := f.User(InstructorID)
+	roles := map[string]string{nid: "instructor"}
+	for id, role := range u.Roles {
+		roles[id] = role
+	}
+	u.Roles = roles
+	f.AddUser(u)
+
+	f.AddPosts(nid, posts...)
+	return nid
+}
+
+// AddPosts adds posts, each the JSON of a piazza.Post, to the class nid.
+func (f *Fixture) AddPosts(nid string, posts ...string) {
+	f.t.Helper()
+	for _, js := range posts {
+		var p piazza.Post
+		f.Unmarshal(js, &p)
+		f.AddPost(nid, p)
+	}
+}
+
+// Unmarshal decodes the JSON js into v and fails the test if it can't.
+func (f *Fixture) Unmarshal(js string, v interface{}) {
+	f.t.Helper()
+	if err := json.Unmarshal([]byte(js), v); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// Student returns a client logged in as the student.
+func (f *Fixture) Student() *piazza.Client {
+	f.t.Helper()
+	return f.login(StudentEmail, StudentPassword)
+}
+
+// Instructor returns a client logged in as the instructor.
+func (f *Fixture) Instructor() *piazza.Client {
+	f.t.Helper()
+	return f.login(InstructorEmail, InstructorPassword)
+}
+
+func (f *Fixture) login(email, password string) *piazza.Client {
+	f.t.Helper()
+	c, err := piazza.MakeClientWithOptions(email, password, f.ClientOptions())
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return c
+}
@@ -0,0 +1,193 @@
+package piazzatest
+
+import (
+	"encoding/json"
+)
+
+// The fake doesn't enforce role permissions; the client checks them before
+// sending anything. Resolving, which no permission covers, is the exception.
+
+func (s *Server) contentUpdate(u *User, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		Nid       string   `json:"nid"`
+		Cid       string   `json:"cid"`
+		Subject   string   `json:"subject"`
+		Content   string   `json:"content"`
+		Revision  int      `json:"revision"`
+		Anonymous string   `json:"anonymous"`
+		Folders   []string `json:"folders"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	n, err := s.userNetwork(u, req.Nid)
+	if err != nil {
+		return nil, err
+	}
+	p, root := n.find(req.Cid)
+	if p == nil {
+		return nil, errNotFound
+	}
+	if req.Revision != len(p.History) {
+		return nil, apiError("The post was edited by someone else; reload to see their changes")
+	}
+
+	if p.Type == "followup" || p.Type == "feedback" {
+		p.Subject = req.Subject
+	} else {
+		var history []interface{}
+		convert(p.History, &history)
+		history = append([]interface{}{revision(u, req.Subject, req.Content, req.Anonymous)}, history...)
+		convert(history, &p.History)
+	}
+	if req.Folders != nil {
+		p.Folders = req.Folders
+	}
+	s.appendLog(root, u, "update", req.Anonymous)
+	return *p, nil
+}
+
+func (s *Server) contentDelete(u *User, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		Nid string `json:"nid"`
+		Cid string `json:"cid"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	n, err := s.userNetwork(u, req.Nid)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range n.posts {
+		if p.ID == req.Cid {
+			n.posts = append(n.posts[:i], n.posts[i+1:]...)
+			return "OK", nil
+		}
+	}
+	return nil, errNotFound
+}
+
+func (s *Server) contentPin(u *User, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		Nid string `json:"nid"`
+		Cid string `json:"cid"`
+		Pin bool   `json:"pin"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	n, err := s.userNetwork(u, req.Nid)
+	if err != nil {
+		return nil, err
+	}
+	p := n.post(req.Cid)
+	if p == nil {
+		return nil, errNotFound
+	}
+	p.BucketName = ""
+	if req.Pin {
+		p.BucketName = "Pinned"
+	}
+	return "OK", nil
+}
+
+func (s *Server) contentMarkResolved(u *User, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		Nid      string `json:"nid"`
+		Cid      string `json:"cid"`
+		Resolved bool   `json:"resolved"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	n, err := s.userNetwork(u, req.Nid)
+	if err != nil {
+		return nil, err
+	}
+	p, root := n.find(req.Cid)
+	if p == nil {
+		return nil, errNotFound
+	}
+	// Only instructors and the authors of the post or the followup may.
+	author := ""
+	if len(root.ChangeLog) > 0 {
+		author = root.ChangeLog[0].UID
+	}
+	if u.Roles[n.ID] != "instructor" && author != u.ID && p.UID != u.ID {
+		return nil, errNoPermission
+	}
+	noAnswer := 1
+	if req.Resolved {
+		noAnswer = 0
+	}
+	root.NoAnswerFollowup = 0
+	for i := range root.Children {
+		child := &root.Children[i]
+		if child.Type != "followup" {
+			continue
+		}
+		if p == root || p == child {
+			child.NoAnswer = noAnswer
+		}
+		root.NoAnswerFollowup += child.NoAnswer
+	}
+	return "OK", nil
+}
+
+func (s *Server) contentMarkGood(u *User, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		Nid string `json:"nid"`
+		Cid string `json:"cid"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	n, err := s.userNetwork(u, req.Nid)
+	if err != nil {
+		return nil, err
+	}
+	p, root := n.find(req.Cid)
+	if p == nil {
+		return nil, errNotFound
+	}
+	for _, uid := range p.TagGoodArr {
+		if uid == u.ID {
+			return "OK", nil
+		}
+	}
+
+	var endorsers []interface{}
+	convert(p.TagGood, &endorsers)
+	endorsers = append(endorsers, map[string]interface{}{
+		"id":   u.ID,
+		"name": u.Name,
+		"role": u.Roles[n.ID],
+	})
+	convert(endorsers, &p.TagGood)
+	p.TagGoodArr = append(p.TagGoodArr, u.ID)
+	s.appendLog(root, u, "tag_good", "no")
+	return "OK", nil
+}
+
+func (s *Server) contentDuplicate(u *User, params json.RawMessage) (interface{}, error) {
+	var req struct {
+		Nid     string `json:"nid"`
+		CidDupe string `json:"cid_dupe"`
+		CidTo   string `json:"cid_to"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	n, err := s.userNetwork(u, req.Nid)
+	if err != nil {
+		return nil, err
+	}
+	dupe, to := n.post(req.CidDupe), n.post(req.CidTo)
+	if dupe == nil || to == nil {
+		return nil, errNotFound
+	}
+	dupe.Status = "duplicate"
+	s.appendLog(dupe, u, "dupe", "no")
+	return "OK", nil
+}
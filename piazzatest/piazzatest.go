@@ -0,0 +1,432 @@
+// Package piazzatest provides an in-memory fake of the Piazza site so code
+// built on piazza.Client can be tested without network access.
+//
+// The fake serves the login form, the /logic/api methods the client uses and
+// the class home pages that carry this.resource_data. It's backed by a model
+// of users, networks, posts and resources that tests seed before use:
+//
+//	s := piazzatest.NewServer()
+//	defer s.Close()
+//	s.AddUser(piazzatest.User{ID: "u1", Email: "a@b.c", Password: "pw"})
+//	s.AddNetwork(piazza.Network{ID: "n1", Name: "CPSC 317"}, "u1")
+//	c, err := piazza.MakeClientWithOptions("a@b.c", "pw", s.ClientOptions())
+package piazzatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+)
+
+// SessionCookie is the name of the cookie the fake uses to track logins.
+const SessionCookie = "session_id"
+
+// User is an account that can log into the fake.
+type User struct {
+	ID       string
+	Email    string
+	Password string
+	Name     string
+	// Roles maps network IDs to the user's role in them, e.g. "student".
+	Roles map[string]string
+	// EmailPrefs is what user.status reports and user.update modifies.
+	EmailPrefs piazza.EmailPrefs
+}
+
+type network struct {
+	piazza.Network
+	posts     []*piazza.Post
+	resources []piazza.Resource
+	lastNr    int // numbers aren't reused after deletes
+}
+
+// Server is a fake Piazza site. Use NewServer to create one.
+type Server struct {
+	// URL is the base URL of the fake, suitable for ClientOptions.BaseURL.
+	URL string
+
+	ts *httptest.Server
+
+	mu       sync.Mutex
+	users    map[string]*User // by ID
+	networks []*network
+	sessions map[string]string // session ID to user ID
+	nextID   int
+	// unavailable API methods fail with 503 Service Unavailable.
+	unavailable map[string]bool
+	// failures are error messages API methods fail with.
+	failures map[string]string
+	files    map[string][]byte // uploads by key
+	// hooks are called before API methods are handled.
+	hooks map[string]func()
+	// feedPageLimit, if set, caps the number of items in a feed page.
+	feedPageLimit int
+}
+
+// NewServer starts a new fake Piazza site. Callers should call Close when
+// finished to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		users:       map[string]*User{},
+		sessions:    map[string]string{},
+		unavailable: map[string]bool{},
+		failures:    map[string]string{},
+		files:       map[string][]byte{},
+		hooks:       map[string]func(){},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/account/login", s.handleLogin)
+	mux.HandleFunc("/logic/api", s.handleAPI)
+	mux.HandleFunc("/redirect/s3", s.handleRedirectS3)
+	mux.HandleFunc("/s3/", s.handleFile)
+	mux.HandleFunc("/", s.handlePage)
+	s.ts = httptest.NewServer(mux)
+	s.URL = s.ts.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.ts.Close()
+}
+
+// ClientOptions returns options that point a piazza.Client at the fake.
+func (s *Server) ClientOptions() piazza.ClientOptions {
+	return piazza.ClientOptions{
+		BaseURL:    s.URL,
+		HTTPClient: s.ts.Client(),
+	}
+}
+
+// newID returns a unique ID in the style of Piazza's.
+func (s *Server) newID() string {
+	s.nextID++
+	return fmt.Sprintf("fake%010s", strconv.FormatInt(int64(s.nextID), 36))
+}
+
+// AddUser adds an account to the fake. A missing ID is generated.
+func (s *Server) AddUser(u User) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(u.ID) == 0 {
+		u.ID = s.newID()
+	}
+	if u.Roles == nil {
+		u.Roles = map[string]string{}
+	}
+	if u.EmailPrefs == nil {
+		u.EmailPrefs = piazza.EmailPrefs{}
+	}
+	s.users[u.ID] = &u
+	return u.ID
+}
+
+// AddNetwork adds a class to the fake and enrolls the given users in it as
+// students unless they already have a role.
+func (s *Server) AddNetwork(n piazza.Network, uids ...string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(n.ID) == 0 {
+		n.ID = s.newID()
+	}
+	s.networks = append(s.networks, &network{Network: n})
+	for _, uid := range uids {
+		u, ok := s.users[uid]
+		if !ok {
+			panic(fmt.Sprintf("piazzatest: unknown user %q", uid))
+		}
+		if _, ok := u.Roles[n.ID]; !ok {
+			u.Roles[n.ID] = "student"
+		}
+	}
+	return n.ID
+}
+
+// AddPost adds a post to a class. A missing ID or Nr is generated and the
+// stored post is returned.
+func (s *Server) AddPost(nid string, p piazza.Post) piazza.Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.network(nid)
+	if n == nil {
+		panic(fmt.Sprintf("piazzatest: unknown network %q", nid))
+	}
+	if len(p.ID) == 0 {
+		p.ID = s.newID()
+	}
+	if p.Nr == 0 {
+		p.Nr = n.nextNr()
+	} else if p.Nr > n.lastNr {
+		n.lastNr = p.Nr
+	}
+	n.posts = append(n.posts, &p)
+	return p
+}
+
+// Post returns the current state of a post.
+func (s *Server) Post(nid, cid string) (piazza.Post, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.network(nid)
+	if n == nil {
+		return piazza.Post{}, false
+	}
+	p := n.post(cid)
+	if p == nil {
+		return piazza.Post{}, false
+	}
+	return *p, true
+}
+
+// AddResource adds an entry to a class's resource page.
+func (s *Server) AddResource(nid string, r piazza.Resource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.network(nid)
+	if n == nil {
+		panic(fmt.Sprintf("piazzatest: unknown network %q", nid))
+	}
+	n.resources = append(n.resources, r)
+}
+
+// User returns the current state of an account.
+func (s *Server) User(uid string) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[uid]
+	if !ok {
+		return User{}, false
+	}
+	return *u, true
+}
+
+func (s *Server) network(nid string) *network {
+	for _, n := range s.networks {
+		if n.ID == nid {
+			return n
+		}
+	}
+	return nil
+}
+
+// nextNr returns the number the next post in the class gets.
+func (n *network) nextNr() int {
+	n.lastNr++
+	return n.lastNr
+}
+
+// post finds a post by ID or by its number.
+func (n *network) post(cid string) *piazza.Post {
+	for _, p := range n.posts {
+		if p.ID == cid || strconv.Itoa(p.Nr) == cid {
+			return p
+		}
+	}
+	return nil
+}
+
+// AddFile uploads a file, such as an attachment, under key and returns the
+// /redirect/s3 link posts and resources use to refer to it. Like Piazza's, the
+// link only works for logged in users.
+func (s *Server) AddFile(key string, data []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[key] = data
+	return "/redirect/s3?bucket=uploads&prefix=" + url.QueryEscape(key)
+}
+
+// SetUnavailable makes calls to an API method fail as if Piazza were down,
+// or restores them.
+func (s *Server) SetUnavailable(method string, unavailable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unavailable[method] = unavailable
+}
+
+// SetError makes calls to an API method fail with msg in the response
+// envelope, or succeed again if msg is empty.
+func (s *Server) SetError(method, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(msg) == 0 {
+		delete(s.failures, method)
+		return
+	}
+	s.failures[method] = msg
+}
+
+// OnCall sets f to be called before each call to an API method is handled, or
+// removes the hook if f is nil. f runs without the server locked, so it may
+// change the server, for example to make something happen between two pages
+// of a feed.
+func (s *Server) OnCall(method string, f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f == nil {
+		delete(s.hooks, method)
+		return
+	}
+	s.hooks[method] = f
+}
+
+// SetFeedPageLimit caps the number of items a feed page has, whatever the
+// client asks for, so paging can be tested with a few posts. Zero removes
+// the cap.
+func (s *Server) SetFeedPageLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.feedPageLimit = limit
+}
+
+// ExpireSessions logs every client out, as if their session cookies had
+// expired.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = map[string]string{}
+}
+
+// sessionUser returns the logged in user for a request, or nil.
+func (s *Server) sessionUser(r *http.Request) *User {
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.users[s.sessions[cookie.Value]]
+}
+
+var loginTmpl = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<body>
+{{if .}}<div id="modal_error_text">{{.}}</div>{{end}}
+<form id="login-form" method="post" action="/account/login">
+<input type="text" name="email" value="">
+<input type="password" name="password" value="">
+<input type="submit" value="Log in">
+</form>
+</body>
+</html>
+`))
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		loginTmpl.Execute(w, "")
+		return
+	}
+
+	email := r.PostFormValue("email")
+	password := r.PostFormValue("password")
+
+	s.mu.Lock()
+	var user *User
+	for _, u := range s.users {
+		if u.Email == email && u.Password == password {
+			user = u
+			break
+		}
+	}
+	if user == nil {
+		s.mu.Unlock()
+		loginTmpl.Execute(w, "Email or password incorrect.")
+		return
+	}
+	session := s.newID()
+	s.sessions[session] = user.ID
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    session,
+		Path:     "/",
+		Expires:  time.Now().Add(24 * time.Hour),
+		HttpOnly: true,
+	})
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// handleRedirectS3 sends logged in users on to the file, as Piazza does with a
+// signed S3 URL.
+func (s *Server) handleRedirectS3(w http.ResponseWriter, r *http.Request) {
+	if s.sessionUser(r) == nil {
+		http.Redirect(w, r, "/account/login", http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/s3/"+r.URL.Query().Get("prefix"), http.StatusFound)
+}
+
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, ok := s.files[strings.TrimPrefix(r.URL.Path, "/s3/")]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(data)
+}
+
+// resourceDataPrefix must match what piazza.Client.FetchResources looks for.
+const resourceDataPrefix = "this.resource_data        = "
+
+// resourcePath is the path of a class's home page, which piazza.Client.ResourceURL
+// appends to the base URL.
+func resourcePath(n piazza.Network) string {
+	term := strings.ToLower(strings.Replace(n.Term, " ", "", -1))
+	return fmt.Sprintf("/%s/%s/%s/home", n.SchoolExt, term, n.ShortNumber)
+}
+
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		fmt.Fprintln(w, "<!DOCTYPE html><html><body>Piazza</body></html>")
+		return
+	}
+	if s.sessionUser(r) == nil {
+		http.Redirect(w, r, "/account/login", http.StatusFound)
+		return
+	}
+
+	s.mu.Lock()
+	var found *network
+	for _, n := range s.networks {
+		if resourcePath(n.Network) == r.URL.Path {
+			found = n
+			break
+		}
+	}
+	var resources []piazza.Resource
+	if found != nil {
+		resources = append([]piazza.Resource{}, found.resources...)
+	}
+	s.mu.Unlock()
+
+	if found == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if resources == nil {
+		resources = []piazza.Resource{}
+	}
+	data, err := json.Marshal(resources)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "<!DOCTYPE html><html><body><script>\n%s%s;\n</script></body></html>\n", resourceDataPrefix, data)
+}
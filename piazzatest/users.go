@@ -0,0 +1,66 @@
+package piazzatest
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// classUser describes u as a member of the class nid.
+func classUser(u *User, nid string) map[string]interface{} {
+	role := u.Roles[nid]
+	return map[string]interface{}{
+		"id":             u.ID,
+		"name":           u.Name,
+		"email":          u.Email,
+		"role":           role,
+		"photo":          nil,
+		"class_sections": []string{},
+		"admin":          instructorRoles[role],
+	}
+}
+
+type usersReq struct {
+	Nid string   `json:"nid"`
+	IDs []string `json:"ids"`
+}
+
+func (s *Server) getUsers(u *User, params json.RawMessage) (interface{}, error) {
+	var req usersReq
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if _, err := s.userNetwork(u, req.Nid); err != nil {
+		return nil, err
+	}
+	users := []map[string]interface{}{}
+	for _, id := range req.IDs {
+		member, ok := s.users[id]
+		if !ok {
+			continue
+		}
+		if _, ok := member.Roles[req.Nid]; ok {
+			users = append(users, classUser(member, req.Nid))
+		}
+	}
+	return users, nil
+}
+
+func (s *Server) getAllUsers(u *User, params json.RawMessage) (interface{}, error) {
+	var req usersReq
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if _, err := s.userNetwork(u, req.Nid); err != nil {
+		return nil, err
+	}
+	users := []map[string]interface{}{}
+	for _, member := range s.users {
+		if _, ok := member.Roles[req.Nid]; ok {
+			users = append(users, classUser(member, req.Nid))
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i]["id"].(string) < users[j]["id"].(string)
+	})
+	return users, nil
+}
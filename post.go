@@ -0,0 +1,208 @@
+package piazza
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PostType is the kind of a top level post.
+type PostType string
+
+// The kinds of posts that can be created.
+const (
+	PostQuestion PostType = "question"
+	PostNote     PostType = "note"
+	PostPoll     PostType = "poll"
+)
+
+// Anonymity is who a post's author is hidden from. The values match those of
+// Network.Anonymity, which is the most a class allows.
+type Anonymity string
+
+// The levels of anonymity, from least to most.
+const (
+	AnonymousNo       Anonymity = "no"
+	AnonymousStudents Anonymity = "stud"
+	AnonymousFull     Anonymity = "full"
+)
+
+func (a Anonymity) level() int {
+	switch a {
+	case AnonymousStudents:
+		return 1
+	case AnonymousFull:
+		return 2
+	}
+	return 0
+}
+
+// Visibility is who can see a post.
+type Visibility string
+
+// The visibilities a post can have.
+const (
+	VisibleAll     Visibility = "all"
+	VisiblePrivate Visibility = "private"
+)
+
+// NewPost describes a post to create with CreatePost.
+type NewPost struct {
+	Type    PostType
+	Subject string
+	// Content is the body as HTML.
+	Content string
+	Folders []string
+	// Anonymous defaults to AnonymousNo.
+	Anonymous Anonymity
+	// Visibility defaults to VisibleAll.
+	Visibility Visibility
+	// Recipients are the user IDs a private post is shared with. Instructors
+	// can always see private posts.
+	Recipients []string
+	// PollOptions are the choices of a poll.
+	PollOptions []string
+	// Announcement marks a note as an announcement, which instructors can use
+	// to email the whole class.
+	Announcement bool
+	// BypassEmail posts without emailing anyone.
+	BypassEmail bool
+}
+
+type postConfig struct {
+	BypassEmail    int      `json:"bypass_email"`
+	IsAnnouncement int      `json:"is_announcement"`
+	FeedGroups     string   `json:"feed_groups,omitempty"`
+	PollOptions    []string `json:"poll_options,omitempty"`
+}
+
+type contentCreateReq struct {
+	Nid       string     `json:"nid"`
+	Type      string     `json:"type"`
+	Subject   string     `json:"subject"`
+	Content   string     `json:"content"`
+	Folders   []string   `json:"folders"`
+	Anonymous string     `json:"anonymous"`
+	Config    postConfig `json:"config"`
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// validateFolders checks that the class has all of the folders.
+func validateFolders(n Network, folders []string) error {
+	known := map[string]bool{}
+	for _, f := range n.Folders {
+		known[f] = true
+	}
+	for _, f := range folders {
+		if !known[f] {
+			return errors.Errorf("class %q has no folder %q", n.ID, f)
+		}
+	}
+	return nil
+}
+
+// validate checks p against what the class allows.
+func (p NewPost) validate(n Network) error {
+	switch p.Type {
+	case PostQuestion, PostNote:
+	case PostPoll:
+		if len(p.PollOptions) < 2 {
+			return errors.New("a poll needs at least two options")
+		}
+	default:
+		return errors.Errorf("unknown post type %q", p.Type)
+	}
+	if len(strings.TrimSpace(p.Subject)) == 0 {
+		return errors.New("a post needs a subject")
+	}
+
+	if err := validateFolders(n, p.Folders); err != nil {
+		return err
+	}
+
+	if p.Anonymous.level() > Anonymity(n.Anonymity).level() {
+		return errors.Errorf("class %q allows anonymity %q, not %q", n.ID, n.Anonymity, p.Anonymous)
+	}
+
+	switch p.Visibility {
+	case VisibleAll:
+		if len(p.Recipients) > 0 {
+			return errors.New("recipients are only for private posts")
+		}
+	case VisiblePrivate:
+	default:
+		return errors.Errorf("unknown visibility %q", p.Visibility)
+	}
+	return nil
+}
+
+// network returns the class with the given ID.
+func (s UserStatus) network(nid string) (Network, error) {
+	for _, n := range s.Result.Networks {
+		if n.ID == nid {
+			return n, nil
+		}
+	}
+	return Network{}, errors.Wrapf(ErrNotFound, "network %q", nid)
+}
+
+// Network returns the class with the given ID from the user's status.
+func (c *Client) Network(ctx context.Context, nid string) (Network, error) {
+	status, err := c.UserStatusContext(ctx)
+	if err != nil {
+		return Network{}, err
+	}
+	return status.network(nid)
+}
+
+// CreatePost creates a question, note or poll in the class nid and returns
+// it. The post is checked against the class's folders and anonymity settings
+// before it's sent.
+func (c *Client) CreatePost(ctx context.Context, nid string, p NewPost) (Post, error) {
+	if len(p.Anonymous) == 0 {
+		p.Anonymous = AnonymousNo
+	}
+	if len(p.Visibility) == 0 {
+		p.Visibility = VisibleAll
+	}
+	n, err := c.Network(ctx, nid)
+	if err != nil {
+		return Post{}, err
+	}
+	if err := p.validate(n); err != nil {
+		return Post{}, err
+	}
+
+	req := contentCreateReq{
+		Nid:       nid,
+		Type:      string(p.Type),
+		Subject:   p.Subject,
+		Content:   p.Content,
+		Folders:   p.Folders,
+		Anonymous: string(p.Anonymous),
+		Config: postConfig{
+			BypassEmail:    boolInt(p.BypassEmail),
+			IsAnnouncement: boolInt(p.Announcement),
+			PollOptions:    p.PollOptions,
+		},
+	}
+	if req.Folders == nil {
+		req.Folders = []string{}
+	}
+	if p.Visibility == VisiblePrivate {
+		req.Config.FeedGroups = strings.Join(append([]string{"instr_" + nid}, p.Recipients...), ",")
+	}
+
+	var resp contentGetResponse
+	if err := c.MakeAPIReqContext(ctx, "content.create", req, &resp); err != nil {
+		return Post{}, err
+	}
+	return resp.Result, nil
+}
@@ -0,0 +1,93 @@
+package piazza_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	piazza "github.com/d4l3k/piazza-api"
+)
+
+func TestCreatePost(t *testing.T) {
+	s, c := newFake(t)
+	ctx := context.Background()
+
+	post, err := c.CreatePost(ctx, "n1", piazza.NewPost{
+		Type:    piazza.PostNote,
+		Subject: "Week 3 announcements",
+		Content: "<p>Lab is cancelled.</p>",
+		Folders: []string{"logistics"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Type != "note" || post.History[0].Subject != "Week 3 announcements" {
+		t.Errorf("CreatePost() = %+v", post)
+	}
+	if _, ok := s.Post("n1", post.ID); !ok {
+		t.Errorf("post %q wasn't stored", post.ID)
+	}
+
+	cases := []struct {
+		post piazza.NewPost
+		want string
+	}{
+		{piazza.NewPost{Type: piazza.PostQuestion, Subject: "q", Folders: []string{"hw9"}}, "no folder"},
+		{piazza.NewPost{Type: piazza.PostQuestion, Subject: "q", Anonymous: piazza.AnonymousFull}, "anonymity"},
+		{piazza.NewPost{Type: piazza.PostPoll, Subject: "q", PollOptions: []string{"yes"}}, "two options"},
+		{piazza.NewPost{Type: "essay", Subject: "q"}, "unknown post type"},
+		{piazza.NewPost{Type: piazza.PostNote}, "subject"},
+		{piazza.NewPost{Type: piazza.PostNote, Subject: "q", Recipients: []string{"u2"}}, "private"},
+	}
+	for _, c2 := range cases {
+		_, err := c.CreatePost(ctx, "n1", c2.post)
+		if err == nil || !strings.Contains(err.Error(), c2.want) {
+			t.Errorf("CreatePost(%+v) = %v; expected error containing %q", c2.post, err, c2.want)
+		}
+	}
+}
+
+func TestReplies(t *testing.T) {
+	s, c := newFake(t)
+	ctx := context.Background()
+
+	if _, err := c.AnswerAsStudent(ctx, "n1", "p1", "<p>First try</p>"); err != nil {
+		t.Fatal(err)
+	}
+	// A second answer revises the first rather than conflicting with it.
+	answer, err := c.AnswerAsStudent(ctx, "n1", "p1", "<p>Second try</p>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(answer.History) != 2 || answer.History[0].Content != "<p>Second try</p>" {
+		t.Errorf("answer.History = %+v", answer.History)
+	}
+	if _, err := c.AnswerAsInstructor(ctx, "n1", "p1", "<p>Official</p>"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AnswerAsInstructor(ctx, "n1", "p2", "<p>Notes have no answers</p>"); err == nil {
+		t.Errorf("AnswerAsInstructor on a note = nil; expected an error")
+	}
+
+	followup, err := c.AddFollowup(ctx, "n1", "p1", "What about part b?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if followup.Type != string(piazza.Followup) || followup.Subject != "What about part b?" {
+		t.Errorf("AddFollowup() = %+v", followup)
+	}
+	if _, err := c.AddFeedback(ctx, "n1", followup.ID, "Same question"); err != nil {
+		t.Fatal(err)
+	}
+
+	post, _ := s.Post("n1", "p1")
+	for _, typ := range []piazza.ChildType{piazza.StudentAnswer, piazza.InstructorAnswer, piazza.Followup} {
+		if _, ok := post.Child(typ); !ok {
+			t.Errorf("post has no %s child", typ)
+		}
+	}
+	f, _ := post.Child(piazza.Followup)
+	if len(f.Children) != 1 || f.Children[0].Type != string(piazza.Feedback) {
+		t.Errorf("followup children = %+v", f.Children)
+	}
+}
@@ -0,0 +1,577 @@
+// Package render converts the HTML Piazza stores post content in to Markdown
+// and plain text.
+//
+// Piazza content is mostly HTML, but Markdown posts are wrapped in <md> tags,
+// LaTeX is written between $$ delimiters and attachments are linked through
+// /redirect/s3. Markdown and math are kept as they are, code blocks become
+// fenced blocks and relative links point at piazza.com, or the site
+// Options.BaseURL names.
+package render
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Options change how content is rendered. The zero value renders for
+// piazza.com.
+type Options struct {
+	// BaseURL is the site relative links are resolved against. It defaults to
+	// piazza.DefaultBaseURL.
+	BaseURL string
+}
+
+// ToMarkdown converts Piazza HTML content to Markdown.
+func ToMarkdown(content string) string {
+	return Options{}.ToMarkdown(content)
+}
+
+// ToPlainText converts Piazza HTML content to readable plain text. Links and
+// images are written as their text followed by the URL.
+func ToPlainText(content string) string {
+	return Options{}.ToPlainText(content)
+}
+
+// ToMarkdown is like the package's ToMarkdown, with o applied.
+func (o Options) ToMarkdown(content string) string {
+	return o.convert(content, markdown)
+}
+
+// ToPlainText is like the package's ToPlainText, with o applied.
+func (o Options) ToPlainText(content string) string {
+	return o.convert(content, plain)
+}
+
+// Attachment is a file hosted by Piazza that content links to.
+type Attachment struct {
+	Name string
+	// URL is the absolute link to the file.
+	URL string
+	// Href is the link as written, which may be relative to the site.
+	Href string
+}
+
+// Attachments lists the uploaded files and images content links to, in the
+// order they appear.
+func Attachments(content string) []Attachment {
+	return Options{}.Attachments(content)
+}
+
+// Attachments is like the package's Attachments, with o applied.
+func (o Options) Attachments(content string) []Attachment {
+	var attachments []Attachment
+	seen := map[string]bool{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			var href string
+			switch n.DataAtom {
+			case atom.A:
+				href = attr(n, "href")
+			case atom.Img:
+				href = attr(n, "src")
+			}
+			abs := o.resolve(href)
+			if name, ok := AttachmentName(abs); ok && !seen[abs] {
+				seen[abs] = true
+				attachments = append(attachments, Attachment{Name: name, URL: abs, Href: href})
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range parse(content) {
+		walk(n)
+	}
+	return attachments
+}
+
+type mode int
+
+const (
+	markdown mode = iota
+	plain
+)
+
+type renderer struct {
+	mode mode
+	opts Options
+	// math is set between $$ delimiters, which may be in different text
+	// nodes.
+	math bool
+}
+
+func parse(content string) []*html.Node {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		// Only reading can fail, and reading a string doesn't.
+		return nil
+	}
+	return nodes
+}
+
+func (o Options) convert(content string, m mode) string {
+	r := &renderer{mode: m, opts: o}
+	var b strings.Builder
+	for _, n := range parse(content) {
+		r.node(&b, n, false)
+	}
+	return clean(b.String())
+}
+
+// clean trims trailing space from lines and collapses runs of blank lines.
+func clean(s string) string {
+	var out []string
+	blank := false
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, " \t")
+		if len(line) == 0 {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// resolve makes links relative to Piazza absolute.
+func (o Options) resolve(href string) string {
+	if strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//") {
+		base := o.BaseURL
+		if len(base) == 0 {
+			base = piazza.DefaultBaseURL
+		}
+		return strings.TrimSuffix(base, "/") + href
+	}
+	return href
+}
+
+// AttachmentName returns the file name of a link to a file Piazza hosts, and
+// false if href isn't one. Uploads are linked as
+// /redirect/s3?bucket=uploads&prefix=attach/.../name or directly by their
+// /attach/ path.
+func AttachmentName(href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil || len(href) == 0 {
+		return "", false
+	}
+	if strings.HasSuffix(u.Path, "/redirect/s3") {
+		prefix := u.Query().Get("prefix")
+		if len(prefix) == 0 {
+			return "", false
+		}
+		return path.Base(prefix), true
+	}
+	if strings.Contains(u.Path, "/attach/") {
+		return path.Base(u.Path), true
+	}
+	return "", false
+}
+
+func (r *renderer) children(b *strings.Builder, n *html.Node, raw bool) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.node(b, c, raw)
+	}
+}
+
+// inline renders the children of n on their own and trims the result.
+func (r *renderer) inline(n *html.Node, raw bool) string {
+	var b strings.Builder
+	r.children(&b, n, raw)
+	return strings.TrimSpace(b.String())
+}
+
+// block renders the children of n on their own as a cleaned up block.
+func (r *renderer) block(n *html.Node) string {
+	var b strings.Builder
+	r.children(&b, n, false)
+	return clean(b.String())
+}
+
+// wrap surrounds text with a Markdown delimiter, leaving it alone in plain
+// text or if it's empty.
+func (r *renderer) wrap(b *strings.Builder, text, delim string) {
+	if r.mode == plain || len(text) == 0 {
+		b.WriteString(text)
+		return
+	}
+	b.WriteString(delim + text + delim)
+}
+
+// text writes a text node. Outside of raw text, whitespace is collapsed as a
+// browser would and Markdown syntax is escaped except in math.
+func (r *renderer) text(b *strings.Builder, s string, raw bool) {
+	lineStart := false
+	if !raw {
+		s = collapse(s)
+		prev := b.String()
+		lineStart = len(prev) == 0 || strings.HasSuffix(prev, "\n")
+		if lineStart || strings.HasSuffix(prev, " ") {
+			s = strings.TrimLeft(s, " ")
+		}
+	}
+	for i, part := range strings.Split(s, "$$") {
+		if i > 0 {
+			b.WriteString("$$")
+			r.math = !r.math
+		}
+		if r.math || raw || r.mode == plain {
+			b.WriteString(part)
+			continue
+		}
+		part = escape(part)
+		if i == 0 && lineStart {
+			part = escapeLineStart(part)
+		}
+		b.WriteString(part)
+	}
+}
+
+// collapse replaces runs of whitespace with a single space.
+func collapse(s string) string {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		if len(s) > 0 {
+			return " "
+		}
+		return ""
+	}
+	out := strings.Join(words, " ")
+	if unicode.IsSpace(rune(s[0])) {
+		out = " " + out
+	}
+	if unicode.IsSpace(rune(s[len(s)-1])) {
+		out += " "
+	}
+	return out
+}
+
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+	">", `\>`,
+	"&", `\&`,
+)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// listNumber matches what would start an ordered list.
+var listNumber = regexp.MustCompile(`^(\d{1,9})([.)])`)
+
+// escapeLineStart escapes what only means something at the start of a line:
+// headings, list markers and thematic breaks.
+func escapeLineStart(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	switch s[0] {
+	case '#', '-', '+', '=':
+		return `\` + s
+	}
+	return listNumber.ReplaceAllString(s, `$1\$2`)
+}
+
+// hrefEscaper keeps link destinations from ending early.
+var hrefEscaper = strings.NewReplacer("(", "%28", ")", "%29", " ", "%20")
+
+// link writes a Markdown link or, with the "!" prefix, an image.
+func link(b *strings.Builder, prefix, text, href string) {
+	fmt.Fprintf(b, "%s[%s](%s)", prefix, text, hrefEscaper.Replace(href))
+}
+
+// punctuation is the ASCII punctuation Markdown lets be escaped.
+const punctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// Unescape undoes the backslash escapes of Markdown written by ToMarkdown,
+// for output that isn't Markdown, such as Slack's mrkdwn. Each escaped
+// character is replaced by what replace returns for it, or by itself if
+// replace is nil. Code, where backslashes are literal, is left alone.
+func Unescape(md string, replace func(c rune) string) string {
+	if replace == nil {
+		replace = func(c rune) string { return string(c) }
+	}
+	var b strings.Builder
+	fenced := false
+	for _, line := range strings.SplitAfter(md, "\n") {
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "```") {
+			fenced = !fenced
+			b.WriteString(line)
+			continue
+		}
+		if fenced {
+			b.WriteString(line)
+			continue
+		}
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case c == '\\' && i+1 < len(line) && strings.IndexByte(punctuation, line[i+1]) >= 0:
+				b.WriteString(replace(rune(line[i+1])))
+				i++
+			case c == '`':
+				// A code span runs to the next run of as many backticks.
+				n := len(line[i:]) - len(strings.TrimLeft(line[i:], "`"))
+				delim := line[i : i+n]
+				span := n
+				if end := strings.Index(line[i+n:], delim); end >= 0 {
+					span += end + n
+				}
+				b.WriteString(line[i : i+span])
+				i += span - 1
+			default:
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.String()
+}
+
+// textContent returns all the text under n as is.
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Br {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+// indent prefixes every line of s but the first with n spaces.
+func indent(s string, n int) string {
+	return strings.Replace(s, "\n", "\n"+strings.Repeat(" ", n), -1)
+}
+
+// prefixLines prefixes every line of s.
+func prefixLines(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(prefix+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *renderer) node(b *strings.Builder, n *html.Node, raw bool) {
+	switch n.Type {
+	case html.TextNode:
+		r.text(b, n.Data, raw)
+		return
+	case html.ElementNode:
+	case html.DocumentNode:
+		r.children(b, n, raw)
+		return
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Head:
+
+	case atom.Br:
+		switch {
+		case r.math:
+			b.WriteString(" ")
+		case r.mode == markdown && !raw:
+			b.WriteString("\\\n")
+		default:
+			b.WriteString("\n")
+		}
+
+	case atom.P, atom.Div, atom.Section, atom.Article:
+		b.WriteString("\n\n")
+		r.children(b, n, raw)
+		b.WriteString("\n\n")
+
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		b.WriteString("\n\n")
+		if r.mode == markdown {
+			level := int(n.Data[1] - '0')
+			b.WriteString(strings.Repeat("#", level) + " ")
+		}
+		b.WriteString(r.inline(n, raw))
+		b.WriteString("\n\n")
+
+	case atom.Strong, atom.B:
+		r.wrap(b, r.inline(n, raw), "**")
+	case atom.Em, atom.I:
+		r.wrap(b, r.inline(n, raw), "*")
+	case atom.S, atom.Strike, atom.Del:
+		r.wrap(b, r.inline(n, raw), "~~")
+
+	case atom.Code:
+		code := textContent(n)
+		if r.mode == plain {
+			b.WriteString(code)
+			break
+		}
+		delim := "`"
+		if strings.Contains(code, "`") {
+			delim = "``"
+		}
+		b.WriteString(delim + code + delim)
+
+	case atom.Pre:
+		code := strings.Trim(textContent(n), "\n")
+		b.WriteString("\n\n")
+		if r.mode == markdown {
+			fmt.Fprintf(b, "```%s\n%s\n```", language(n), code)
+		} else {
+			b.WriteString(code)
+		}
+		b.WriteString("\n\n")
+
+	case atom.A:
+		href := r.opts.resolve(attr(n, "href"))
+		text := r.inline(n, raw)
+		if len(text) == 0 {
+			if name, ok := AttachmentName(href); ok {
+				text = name
+			} else {
+				text = href
+			}
+		}
+		switch {
+		case len(href) == 0:
+			b.WriteString(text)
+		case r.mode == markdown:
+			link(b, "", text, href)
+		case text == href:
+			b.WriteString(href)
+		default:
+			fmt.Fprintf(b, "%s (%s)", text, href)
+		}
+
+	case atom.Img:
+		src := r.opts.resolve(attr(n, "src"))
+		alt := attr(n, "alt")
+		if r.mode == markdown {
+			link(b, "!", escape(alt), src)
+			break
+		}
+		if len(alt) == 0 {
+			alt = "image"
+		}
+		fmt.Fprintf(b, "[%s] (%s)", alt, src)
+
+	case atom.Ul, atom.Ol:
+		b.WriteString("\n\n")
+		i := 0
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+				continue
+			}
+			i++
+			marker := "- "
+			if n.DataAtom == atom.Ol {
+				marker = fmt.Sprintf("%d. ", i)
+			}
+			b.WriteString(marker + indent(r.block(c), len(marker)) + "\n")
+		}
+		b.WriteString("\n")
+
+	case atom.Blockquote:
+		b.WriteString("\n\n" + prefixLines(r.block(n), "> ") + "\n\n")
+
+	case atom.Hr:
+		b.WriteString("\n\n---\n\n")
+
+	case atom.Table:
+		b.WriteString("\n\n")
+		r.table(b, n)
+		b.WriteString("\n\n")
+
+	default:
+		if n.Data == "md" {
+			// Markdown is kept as written.
+			b.WriteString("\n\n")
+			r.children(b, n, true)
+			b.WriteString("\n\n")
+			break
+		}
+		r.children(b, n, raw)
+	}
+}
+
+// language returns the language of a code block from a "language-x" or
+// "lang-x" class, as highlighters use.
+func language(n *html.Node) string {
+	for _, class := range strings.Fields(attr(n, "class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if strings.HasPrefix(class, prefix) {
+				return strings.TrimPrefix(class, prefix)
+			}
+		}
+	}
+	return ""
+}
+
+func (r *renderer) table(b *strings.Builder, n *html.Node) {
+	var rows [][]string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if c.DataAtom != atom.Tr {
+				walk(c)
+				continue
+			}
+			var row []string
+			for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+					text := strings.Replace(r.block(cell), "\n", " ", -1)
+					row = append(row, strings.Replace(text, "|", `\|`, -1))
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	walk(n)
+
+	for i, row := range rows {
+		if r.mode == plain {
+			b.WriteString(strings.Join(row, " | ") + "\n")
+			continue
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString(strings.Repeat("| --- ", len(row)) + "|\n")
+		}
+	}
+}
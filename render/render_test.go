@@ -0,0 +1,129 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToMarkdown(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{
+			`<p>Hello <b>world</b>, see <a href="https://fn.lc">this</a>.</p><p>Second   line<br />next</p>`,
+			"Hello **world**, see [this](https://fn.lc).\n\nSecond line\\\nnext",
+		},
+		{
+			`<p>Use snake_case and $$x_1 * y_2$$ please</p>`,
+			`Use snake\_case and $$x_1 * y_2$$ please`,
+		},
+		{
+			`<p>Inline $$\frac{a}{b}$$<br/>and $$a_<i>i</i>$$</p>`,
+			"Inline $$\\frac{a}{b}$$\\\nand $$a_*i*$$",
+		},
+		{
+			"<md>**Already** markdown\n\n- one\n- two</md>",
+			"**Already** markdown\n\n- one\n- two",
+		},
+		{
+			"<pre class=\"language-go\">func main() {\n\tfmt.Println(\"hi\")\n}\n</pre><p>run <code>go run</code></p>",
+			"```go\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```\n\nrun `go run`",
+		},
+		{
+			`<ul><li>a</li><li>b<ol><li>c</li></ol></li></ul>`,
+			"- a\n- b\n\n  1. c",
+		},
+		{
+			`<img src="/img/x.png" alt="diagram"><a href="/redirect/s3?bucket=uploads&amp;prefix=attach%2Fn1%2Fu1%2Fnotes.pdf"></a>`,
+			"![diagram](https://piazza.com/img/x.png)[notes.pdf](https://piazza.com/redirect/s3?bucket=uploads&prefix=attach%2Fn1%2Fu1%2Fnotes.pdf)",
+		},
+		{
+			`<blockquote><p>quoted</p><p>twice</p></blockquote><h2>Title</h2><table><tr><th>a</th><th>b</th></tr><tr><td>1</td><td>2</td></tr></table>`,
+			"> quoted\n>\n> twice\n\n## Title\n\n| a | b |\n| --- | --- |\n| 1 | 2 |",
+		},
+		{
+			`<p># not a title</p><p>- not a list<br>+ nor this<br>1. nor this &lt;b&gt; &amp; that</p><p>---</p>`,
+			"\\# not a title\n\n\\- not a list\\\n\\+ nor this\\\n1\\. nor this \\<b\\> \\& that\n\n\\---",
+		},
+		{
+			`<a href="https://en.wikipedia.org/wiki/Go_(game)">Go</a> <img src="/img/my plot.png" alt="plot">`,
+			"[Go](https://en.wikipedia.org/wiki/Go_%28game%29) ![plot](https://piazza.com/img/my%20plot.png)",
+		},
+	}
+	for _, c := range cases {
+		if got := ToMarkdown(c.in); got != c.want {
+			t.Errorf("ToMarkdown(%q) =\n%s\nnot\n%s", c.in, got, c.want)
+		}
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	md := ToMarkdown("<p>2*3 in snake_case &lt;b&gt;, not <code>a\\*b</code></p><pre>x\\_y</pre>")
+	if got, want := Unescape(md, nil), "2*3 in snake_case <b>, not `a\\*b`\n\n```\nx\\_y\n```"; got != want {
+		t.Errorf("Unescape(%q) = %q; not %q", md, got, want)
+	}
+	held := Unescape(`\*a\* \\`, func(c rune) string { return "<" + string(c) + ">" })
+	if want := `<*>a<*> <\>`; held != want {
+		t.Errorf("Unescape() with replace = %q; not %q", held, want)
+	}
+}
+
+func TestToPlainText(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{
+			`<p>Hello <b>world</b>, see <a href="https://fn.lc">this</a> and <a href="https://fn.lc">https://fn.lc</a>.</p><p>snake_case<br>$$x_1$$</p>`,
+			"Hello world, see this (https://fn.lc) and https://fn.lc.\n\nsnake_case\n$$x_1$$",
+		},
+		{
+			"<pre>a  b\n  c</pre><ol><li>one</li><li>two</li></ol><img src=\"https://fn.lc/x.png\">",
+			"a  b\n  c\n\n1. one\n2. two\n\n[image] (https://fn.lc/x.png)",
+		},
+		{
+			"<md>*keep*\n   me</md><script>alert(1)</script>",
+			"*keep*\n   me",
+		},
+	}
+	for _, c := range cases {
+		if got := ToPlainText(c.in); got != c.want {
+			t.Errorf("ToPlainText(%q) =\n%s\nnot\n%s", c.in, got, c.want)
+		}
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	got := Attachments(`<p><a href="/redirect/s3?bucket=uploads&amp;prefix=attach%2Fn1%2Fu1%2Fhw1.pdf">hw</a>
+<img src="https://d1b10bmlvqabco.cloudfront.net/attach/n1/u1/abc/plot.png">
+<a href="https://fn.lc">not one</a>
+<a href="/redirect/s3?bucket=uploads&amp;prefix=attach%2Fn1%2Fu1%2Fhw1.pdf">again</a></p>`)
+	want := []Attachment{
+		{
+			Name: "hw1.pdf",
+			URL:  "https://piazza.com/redirect/s3?bucket=uploads&prefix=attach%2Fn1%2Fu1%2Fhw1.pdf",
+			Href: "/redirect/s3?bucket=uploads&prefix=attach%2Fn1%2Fu1%2Fhw1.pdf",
+		},
+		{
+			Name: "plot.png",
+			URL:  "https://d1b10bmlvqabco.cloudfront.net/attach/n1/u1/abc/plot.png",
+			Href: "https://d1b10bmlvqabco.cloudfront.net/attach/n1/u1/abc/plot.png",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Attachments() = %+v; not %+v", got, want)
+	}
+}
+
+func TestOptionsBaseURL(t *testing.T) {
+	o := Options{BaseURL: "http://127.0.0.1:8080/"}
+	content := `<p><a href="/class/n1?cid=2">@2</a> <a href="https://fn.lc">fn.lc</a><img src="/redirect/s3?bucket=uploads&amp;prefix=attach%2Fn1%2Fu1%2Fplot.png"></p>`
+	if got, want := o.ToMarkdown(content), "[@2](http://127.0.0.1:8080/class/n1?cid=2) [fn.lc](https://fn.lc)![](http://127.0.0.1:8080/redirect/s3?bucket=uploads&prefix=attach%2Fn1%2Fu1%2Fplot.png)"; got != want {
+		t.Errorf("ToMarkdown() =\n%s\nnot\n%s", got, want)
+	}
+	if got, want := o.ToPlainText(`<a href="/class/n1?cid=2">@2</a>`), "@2 (http://127.0.0.1:8080/class/n1?cid=2)"; got != want {
+		t.Errorf("ToPlainText() = %q; not %q", got, want)
+	}
+	if a := o.Attachments(content); len(a) != 1 || a[0].URL != "http://127.0.0.1:8080/redirect/s3?bucket=uploads&prefix=attach%2Fn1%2Fu1%2Fplot.png" {
+		t.Errorf("Attachments() = %+v", a)
+	}
+}
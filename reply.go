@@ -0,0 +1,116 @@
+package piazza
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ChildType is the kind of a reply in Post.Children.
+type ChildType string
+
+// The kinds of replies a post can have.
+const (
+	StudentAnswer    ChildType = "s_answer"
+	InstructorAnswer ChildType = "i_answer"
+	Followup         ChildType = "followup"
+	Feedback         ChildType = "feedback"
+)
+
+// Child returns the first direct reply of the given type, if any. A post has at
+// most one student and one instructor answer.
+func (p Post) Child(typ ChildType) (Post, bool) {
+	for _, child := range p.Children {
+		if child.Type == string(typ) {
+			return child, true
+		}
+	}
+	return Post{}, false
+}
+
+type contentAnswerReq struct {
+	Nid       string `json:"nid"`
+	Cid       string `json:"cid"`
+	Type      string `json:"type"`
+	Content   string `json:"content"`
+	Revision  int    `json:"revision"`
+	Anonymous string `json:"anonymous"`
+}
+
+type contentReplyReq struct {
+	Nid       string `json:"nid"`
+	Cid       string `json:"cid"`
+	Type      string `json:"type"`
+	Subject   string `json:"subject"`
+	Content   string `json:"content"`
+	Anonymous string `json:"anonymous"`
+}
+
+// answer creates or revises the answer of the given type. Piazza keeps a single
+// answer per type and expects edits to name the revision they replace, which
+// is the number of revisions the answer already has.
+func (c *Client) answer(ctx context.Context, nid, postID string, typ ChildType, content string) (Post, error) {
+	post, err := c.ContentContext(ctx, nid, postID)
+	if err != nil {
+		return Post{}, err
+	}
+	if post.Type != string(PostQuestion) {
+		return Post{}, errors.Errorf("post %q is a %s; only questions have answers", postID, post.Type)
+	}
+	revision := 0
+	if existing, ok := post.Child(typ); ok {
+		revision = len(existing.History)
+	}
+
+	req := contentAnswerReq{
+		Nid:       nid,
+		Cid:       post.ID,
+		Type:      string(typ),
+		Content:   content,
+		Revision:  revision,
+		Anonymous: string(AnonymousNo),
+	}
+	var resp contentGetResponse
+	if err := c.MakeAPIReqContext(ctx, "content.answer", req, &resp); err != nil {
+		return Post{}, err
+	}
+	return resp.Result, nil
+}
+
+// AnswerAsStudent writes the students' answer to a question, revising it if
+// there already is one.
+func (c *Client) AnswerAsStudent(ctx context.Context, nid, postID, content string) (Post, error) {
+	return c.answer(ctx, nid, postID, StudentAnswer, content)
+}
+
+// AnswerAsInstructor writes the instructors' answer to a question, revising it
+// if there already is one.
+func (c *Client) AnswerAsInstructor(ctx context.Context, nid, postID, content string) (Post, error) {
+	return c.answer(ctx, nid, postID, InstructorAnswer, content)
+}
+
+// reply creates a followup or feedback. Piazza keeps their text in Subject.
+func (c *Client) reply(ctx context.Context, nid, parentID string, typ ChildType, text string) (Post, error) {
+	req := contentReplyReq{
+		Nid:       nid,
+		Cid:       parentID,
+		Type:      string(typ),
+		Subject:   text,
+		Anonymous: string(AnonymousNo),
+	}
+	var resp contentGetResponse
+	if err := c.MakeAPIReqContext(ctx, "content.create", req, &resp); err != nil {
+		return Post{}, err
+	}
+	return resp.Result, nil
+}
+
+// AddFollowup starts a followup discussion on a post.
+func (c *Client) AddFollowup(ctx context.Context, nid, postID, text string) (Post, error) {
+	return c.reply(ctx, nid, postID, Followup, text)
+}
+
+// AddFeedback replies to a followup discussion.
+func (c *Client) AddFeedback(ctx context.Context, nid, followupID, text string) (Post, error) {
+	return c.reply(ctx, nid, followupID, Feedback, text)
+}
@@ -0,0 +1,144 @@
+package piazza
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// SearchOptions controls Search.
+type SearchOptions struct {
+	// Limit caps the number of hits if set.
+	Limit int
+	// Folder, if set, only returns posts in that folder.
+	Folder string
+	// LocalOnly skips the server and searches the cached feed instead.
+	LocalOnly bool
+	// OnFallback, if set, is called with the server's error when the feed is
+	// searched because the server search failed.
+	OnFallback func(err error)
+}
+
+// SearchHit is a post matching a search.
+type SearchHit struct {
+	ID      string   `json:"id"`
+	Nr      int      `json:"nr"`
+	Subject string   `json:"subject"`
+	Snippet string   `json:"content_snipet"`
+	Score   float64  `json:"score"`
+	Folders []string `json:"folders"`
+}
+
+type searchReq struct {
+	Nid   string `json:"nid"`
+	Query string `json:"query"`
+}
+
+type searchResponse struct {
+	Aid    string      `json:"aid"`
+	Error  interface{} `json:"error"`
+	Result []SearchHit `json:"result"`
+}
+
+// Search looks for posts matching query in class nid using Piazza's
+// "network.search". If the server search is unavailable, the most recently
+// fetched feed of the class is searched instead, fetching it if needed, and
+// opts.OnFallback is told why. Being rate limited isn't worked around, since
+// fetching the feed would only make it worse.
+func (c *Client) Search(ctx context.Context, nid, query string, opts SearchOptions) ([]SearchHit, error) {
+	if !opts.LocalOnly {
+		var resp searchResponse
+		err := c.MakeAPIReqContext(ctx, "network.search", searchReq{Nid: nid, Query: query}, &resp)
+		if err == nil {
+			return filterHits(resp.Result, opts), nil
+		}
+		if ctx.Err() != nil || isKind(err, ErrNotLoggedIn) || isKind(err, ErrPermissionDenied) || isKind(err, ErrRateLimited) {
+			return nil, err
+		}
+		if opts.OnFallback != nil {
+			opts.OnFallback(err)
+		}
+	}
+
+	items, ok := c.cachedFeed(nid)
+	if !ok {
+		feed, err := c.FeedContext(ctx, nid)
+		if err != nil {
+			return nil, err
+		}
+		items = feed.Result.Feed
+	}
+	return SearchFeed(items, query, opts), nil
+}
+
+func filterHits(hits []SearchHit, opts SearchOptions) []SearchHit {
+	var out []SearchHit
+	for _, h := range hits {
+		if len(opts.Folder) > 0 && !hasString(h.Folders, opts.Folder) {
+			continue
+		}
+		out = append(out, h)
+		if opts.Limit > 0 && len(out) >= opts.Limit {
+			break
+		}
+	}
+	return out
+}
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchFeed searches feed items locally. Every word of the query has to
+// appear in the subject or snippet of a hit, and matches in the subject count
+// for more. Hits are ordered by score and then by newest post.
+func SearchFeed(items []FeedItem, query string, opts SearchOptions) []SearchHit {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var hits []SearchHit
+	for _, item := range items {
+		if len(opts.Folder) > 0 && !hasString(item.Folders, opts.Folder) {
+			continue
+		}
+		subject := strings.ToLower(item.Subject)
+		snippet := strings.ToLower(item.ContentSnipet)
+		score := 0.0
+		for _, term := range terms {
+			n := 3*strings.Count(subject, term) + strings.Count(snippet, term)
+			if n == 0 {
+				score = 0
+				break
+			}
+			score += float64(n)
+		}
+		if score == 0 {
+			continue
+		}
+		hits = append(hits, SearchHit{
+			ID:      item.ID,
+			Nr:      item.Nr,
+			Subject: item.Subject,
+			Snippet: item.ContentSnipet,
+			Score:   score,
+			Folders: item.Folders,
+		})
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Nr > hits[j].Nr
+	})
+	if opts.Limit > 0 && len(hits) > opts.Limit {
+		hits = hits[:opts.Limit]
+	}
+	return hits
+}
@@ -0,0 +1,73 @@
+package piazza_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	piazza "github.com/d4l3k/piazza-api"
+)
+
+func TestSearch(t *testing.T) {
+	s, c := newFake(t)
+	ctx := context.Background()
+	s.AddPost("n1", mustPost(t, `{
+		"id": "p3",
+		"type": "question",
+		"folders": ["logistics"],
+		"history": [{"subject": "Midterm room", "content": "Where is the midterm?"}]
+	}`))
+
+	hits, err := c.Search(ctx, "n1", "midterm", piazza.SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].ID != "p3" || hits[0].Nr != 3 || hits[0].Score <= 0 {
+		t.Fatalf("Search() = %+v", hits)
+	}
+
+	// Being rate limited is reported rather than made worse by fetching the
+	// feed.
+	s.SetError("network.search", "Too many requests, slow down")
+	fellBack := false
+	opts := piazza.SearchOptions{OnFallback: func(error) { fellBack = true }}
+	if _, err := c.Search(ctx, "n1", "midterm", opts); !errors.Is(err, piazza.ErrRateLimited) {
+		t.Errorf("Search() while rate limited = %v; not %v", err, piazza.ErrRateLimited)
+	}
+	if fellBack {
+		t.Errorf("Search() while rate limited fell back to the feed")
+	}
+	s.SetError("network.search", "")
+
+	// With the server search down the cached feed is searched instead.
+	s.SetUnavailable("network.search", true)
+	hits, err = c.Search(ctx, "n1", "Midterm ROOM", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].ID != "p3" || !fellBack {
+		t.Fatalf("Search() with fallback = %+v, fell back %v", hits, fellBack)
+	}
+	hits, err = c.Search(ctx, "n1", "midterm", piazza.SearchOptions{Folder: "hw1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 0 {
+		t.Errorf("Search() in hw1 = %+v; expected no hits", hits)
+	}
+}
+
+func TestSearchFeed(t *testing.T) {
+	items := []piazza.FeedItem{
+		{ID: "a", Nr: 1, Subject: "Lab 2", ContentSnipet: "the lab 2 handout"},
+		{ID: "b", Nr: 2, Subject: "Midterm", ContentSnipet: "covers lab 1 and lab 2"},
+		{ID: "c", Nr: 3, Subject: "Office hours", ContentSnipet: "moved"},
+	}
+	hits := piazza.SearchFeed(items, "lab 2", piazza.SearchOptions{})
+	if len(hits) != 2 || hits[0].ID != "a" || hits[1].ID != "b" {
+		t.Errorf("SearchFeed() = %+v", hits)
+	}
+	if hits := piazza.SearchFeed(items, "lab", piazza.SearchOptions{Limit: 1}); len(hits) != 1 {
+		t.Errorf("SearchFeed() with limit = %+v", hits)
+	}
+}
@@ -0,0 +1,233 @@
+package piazza
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// savedSession is the serialized form of a logged in session.
+type savedSession struct {
+	BaseURL string        `json:"base_url"`
+	Aid     string        `json:"aid"`
+	Cookies []savedCookie `json:"cookies"`
+}
+
+// savedCookie is a cookie with all its attributes and the URL that set it,
+// which the jar needs to accept it again.
+type savedCookie struct {
+	URL string `json:"url"`
+	*http.Cookie
+}
+
+// cookieLog is a cookie jar that remembers the cookies it was given as set,
+// since http.CookieJar only hands back their names and values.
+type cookieLog struct {
+	http.CookieJar
+
+	mu  sync.Mutex
+	set map[string]savedCookie // by URL host, domain, path and name
+}
+
+func (l *cookieLog) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	l.CookieJar.SetCookies(u, cookies)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.set == nil {
+		l.set = map[string]savedCookie{}
+	}
+	for _, cookie := range cookies {
+		key := strings.Join([]string{u.Host, cookie.Domain, cookie.Path, cookie.Name}, "\x00")
+		if cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())) {
+			delete(l.set, key)
+			continue
+		}
+		copied := *cookie
+		l.set[key] = savedCookie{URL: u.String(), Cookie: &copied}
+	}
+}
+
+// saved returns the cookies that are still in the jar. Ones that reached it
+// some other way, e.g. in a jar passed to NewClient, only have a name and a
+// value and are saved as set by base.
+func (l *cookieLog) saved(base *url.URL) []savedCookie {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keys := make([]string, 0, len(l.set))
+	for key := range l.set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var saved []savedCookie
+	logged := map[string]bool{}
+	for _, key := range keys {
+		sc := l.set[key]
+		u, err := url.Parse(sc.URL)
+		if err != nil {
+			continue
+		}
+		for _, cookie := range l.Cookies(u) {
+			if cookie.Name == sc.Name && cookie.Value == sc.Value {
+				saved = append(saved, sc)
+				logged[sc.Name] = true
+				break
+			}
+		}
+	}
+	for _, cookie := range l.Cookies(base) {
+		if !logged[cookie.Name] {
+			saved = append(saved, savedCookie{URL: base.String(), Cookie: cookie})
+		}
+	}
+	return saved
+}
+
+// SaveSession writes the client's cookies, with their domains, paths and expiry
+// times, and aid to w so that a later process can pick up the session with
+// RestoreClient instead of logging in.
+// The output contains credentials and should be kept private.
+func (c *Client) SaveSession(w io.Writer) error {
+	c.mu.Lock()
+	sess := savedSession{
+		BaseURL: c.base.String(),
+		Aid:     c.aid,
+		Cookies: c.cookies.saved(c.base),
+	}
+	c.mu.Unlock()
+	return json.NewEncoder(w).Encode(sess)
+}
+
+// SaveSessionFile is like SaveSession but writes to the named file, which is
+// only readable by the current user.
+func (c *Client) SaveSessionFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	// The file may have existed with looser permissions.
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if err := c.SaveSession(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// RestoreSession loads cookies and aid written by SaveSession into the client.
+func (c *Client) RestoreSession(r io.Reader) error {
+	var sess savedSession
+	if err := json.NewDecoder(r).Decode(&sess); err != nil {
+		return errors.Wrap(err, "decoding session")
+	}
+	c.restore(sess)
+	return nil
+}
+
+func (c *Client) restore(sess savedSession) {
+	for _, sc := range sess.Cookies {
+		if sc.Cookie == nil {
+			continue
+		}
+		u, err := url.Parse(sc.URL)
+		if err != nil || len(sc.URL) == 0 {
+			u = c.base
+		}
+		c.http.Jar.SetCookies(u, []*http.Cookie{sc.Cookie})
+	}
+	c.mu.Lock()
+	c.aid = sess.Aid
+	c.mu.Unlock()
+}
+
+// RestoreClient returns a client using a session written by SaveSession.
+func RestoreClient(r io.Reader) (*Client, error) {
+	return RestoreClientWithOptions(r, ClientOptions{})
+}
+
+// RestoreClientWithOptions is like RestoreClient but configures the client with
+// opts. If opts.BaseURL is empty the site the session was saved from is used.
+func RestoreClientWithOptions(r io.Reader, opts ClientOptions) (*Client, error) {
+	var sess savedSession
+	if err := json.NewDecoder(r).Decode(&sess); err != nil {
+		return nil, errors.Wrap(err, "decoding session")
+	}
+	if len(opts.BaseURL) == 0 {
+		opts.BaseURL = sess.BaseURL
+	}
+	c, err := NewClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	c.restore(sess)
+	return c, nil
+}
+
+// RestoreClientFile is like RestoreClientWithOptions but reads the session
+// from the named file.
+func RestoreClientFile(path string, opts ClientOptions) (*Client, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return RestoreClientWithOptions(f, opts)
+}
+
+// CheckSession makes a cheap API call to verify that the client's session is
+// still logged in. It returns an error matching ErrNotLoggedIn if not.
+func (c *Client) CheckSession(ctx context.Context) error {
+	_, err := c.UserStatusContext(ctx)
+	return err
+}
+
+// loginPath returns the path of the login page, which Piazza redirects to when
+// the session has expired.
+func (c *Client) loginPath() string {
+	u, err := url.Parse(c.loginURL)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+// renewSession logs in again with the remembered credentials, unless another
+// caller already did so since session was observed. cause is returned if
+// there are no credentials to log in with.
+func (c *Client) renewSession(ctx context.Context, session int, cause error) error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+
+	c.mu.Lock()
+	username, password, current := c.username, c.password, c.session
+	c.mu.Unlock()
+	if current != session {
+		return nil
+	}
+	if len(username) == 0 {
+		return cause
+	}
+
+	c.mu.Lock()
+	c.aid = ""
+	c.mu.Unlock()
+	err := c.login(ctx, username, password)
+	if c.onRenew != nil {
+		c.onRenew(err)
+	}
+	return errors.Wrap(err, "renewing session")
+}
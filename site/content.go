@@ -0,0 +1,105 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/d4l3k/piazza-api/render"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// links maps links as they appear in content, relative or made absolute by
+// the render package against baseURL, to where the site has the file.
+type links struct {
+	baseURL string
+	local   map[string]string
+}
+
+func newLinks(baseURL string) links {
+	return links{baseURL: baseURL, local: map[string]string{}}
+}
+
+// add records that href is at local.
+func (l links) add(href, local string) {
+	l.local[href] = local
+	if strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//") {
+		l.local[strings.TrimSuffix(l.baseURL, "/")+href] = local
+	}
+}
+
+// Transform implements parser.ASTTransformer by pointing links and images at
+// local copies.
+func (l links) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Link:
+			if local, ok := l.local[string(n.Destination)]; ok {
+				n.Destination = []byte(local)
+			}
+		case *ast.Image:
+			if local, ok := l.local[string(n.Destination)]; ok {
+				n.Destination = []byte(local)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// mathRegexp matches LaTeX between $$ delimiters.
+var mathRegexp = regexp.MustCompile(`(?s)\$\$.*?\$\$`)
+
+// mathPlaceholder stands in for the i'th piece of math while the rest of the
+// content goes through Markdown. It has no Markdown syntax in it.
+func mathPlaceholder(i int) string {
+	return fmt.Sprintf("PIAZZAMATH%dX", i)
+}
+
+// toHTML renders Piazza content as safe HTML. The content goes through
+// Markdown, so raw HTML and scripts are dropped, and math is passed through
+// untouched for MathJax to typeset.
+func toHTML(content string, l links) (template.HTML, error) {
+	md := render.Options{BaseURL: l.baseURL}.ToMarkdown(content)
+	var math []string
+	md = mathRegexp.ReplaceAllStringFunc(md, func(m string) string {
+		math = append(math, m)
+		return mathPlaceholder(len(math) - 1)
+	})
+
+	converter := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithASTTransformers(util.Prioritized(l, 100))),
+	)
+	var buf bytes.Buffer
+	if err := converter.Convert([]byte(md), &buf); err != nil {
+		return "", err
+	}
+
+	out := buf.String()
+	for i, m := range math {
+		out = strings.Replace(out, mathPlaceholder(i), html.EscapeString(m), -1)
+	}
+	return template.HTML(out), nil
+}
+
+// plainText is what the search index holds of some content.
+func plainText(content, baseURL string) string {
+	return strings.Join(strings.Fields(render.Options{BaseURL: baseURL}.ToPlainText(content)), " ")
+}
+
+// postLink is the path of a post's page relative to the site.
+func postLink(nr int) string {
+	return PostsDir + "/" + strconv.Itoa(nr) + ".html"
+}
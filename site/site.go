@@ -0,0 +1,449 @@
+// Package site renders a class archive written by the archive package as a
+// static web site, so the class can be browsed once it's gone from Piazza.
+// The site needs no server and is laid out as:
+//
+//	index.html                posts grouped by folder, with search
+//	posts/<nr>.html           each post with its answers, followups and
+//	                          feedback
+//	resources.html            the class resources
+//	search.js                 the search index
+//	style.css
+//	attachments/, resources/  the archived files posts and resources link to
+//
+// Content is rendered from HTML through Markdown, which drops scripts and
+// other raw HTML. LaTeX is left for MathJax to typeset in the browser.
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/archive"
+	"github.com/pkg/errors"
+)
+
+// The files and directories of a site.
+const (
+	IndexFile     = "index.html"
+	ResourcesFile = "resources.html"
+	PostsDir      = "posts"
+	SearchFile    = "search.js"
+	StyleFile     = "style.css"
+)
+
+// DefaultMathJaxURL is the MathJax script pages load when
+// Options.MathJaxURL is unset.
+const DefaultMathJaxURL = "https://cdn.jsdelivr.net/npm/mathjax@3/es5/tex-chtml.js"
+
+// Unfiled is the index heading of posts without a folder.
+const Unfiled = "Unfiled"
+
+// Options controls Generate.
+type Options struct {
+	// MathJaxURL is the script that typesets LaTeX.
+	MathJaxURL string
+	// Deleted includes posts that were deleted from Piazza after they were
+	// archived.
+	Deleted bool
+	// BaseURL is the site relative links in the archive point at. It
+	// defaults to the one the archive was made from, or
+	// piazza.DefaultBaseURL for archives that don't record it.
+	BaseURL string
+	// Logf, if set, is called with progress messages.
+	Logf func(format string, args ...interface{})
+}
+
+func (o Options) logf(format string, args ...interface{}) {
+	if o.Logf != nil {
+		o.Logf(format, args...)
+	}
+}
+
+// page is what every page template gets.
+type page struct {
+	// Root is the path from the page to the top of the site.
+	Root       string
+	Title      string
+	Network    piazza.Network
+	MathJaxURL string
+}
+
+type folder struct {
+	Name  string
+	Posts []archive.PostEntry
+}
+
+type indexPage struct {
+	page
+	Folders []folder
+}
+
+// reply is a post or one of its replies as a page shows it.
+type reply struct {
+	Subject   string
+	Content   template.HTML
+	Created   piazza.Time
+	Edited    piazza.Time
+	Anonymous bool
+	// Unresolved is set on followups that haven't been resolved.
+	Unresolved bool
+	// Endorsements are the names of users who marked it good.
+	Endorsements []string
+	// Replies are followups to a post or feedback on a followup.
+	Replies []reply
+}
+
+type postPage struct {
+	page
+	Entry            archive.PostEntry
+	Type             string
+	Post             reply
+	InstructorAnswer *reply
+	StudentAnswer    *reply
+}
+
+type resource struct {
+	Subject string
+	Date    string
+	Link    string
+}
+
+type resourceSection struct {
+	Title     string
+	Resources []resource
+}
+
+type resourcesPage struct {
+	page
+	Sections []resourceSection
+}
+
+// searchEntry is a post in the search index.
+type searchEntry struct {
+	Nr      int      `json:"nr"`
+	Subject string   `json:"subject"`
+	Folders []string `json:"folders"`
+	URL     string   `json:"url"`
+	Text    string   `json:"text"`
+}
+
+// Generate renders the archive in dir as a site in out, creating it if needed.
+// Files the archive downloaded are copied into the site.
+func Generate(dir, out string, opts Options) error {
+	m, err := archive.ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+	var n piazza.Network
+	if err := readJSON(filepath.Join(dir, archive.NetworkFile), &n); err != nil {
+		return err
+	}
+	if len(opts.MathJaxURL) == 0 {
+		opts.MathJaxURL = DefaultMathJaxURL
+	}
+	if len(opts.BaseURL) == 0 {
+		opts.BaseURL = m.BaseURL
+	}
+	if len(opts.BaseURL) == 0 {
+		opts.BaseURL = piazza.DefaultBaseURL
+	}
+	base := page{Title: n.Name, Network: n, MathJaxURL: opts.MathJaxURL}
+	if len(base.Title) == 0 {
+		base.Title = m.Network
+	}
+
+	var entries []archive.PostEntry
+	index := []searchEntry{}
+	for _, e := range m.Posts {
+		if e.Deleted && !opts.Deleted {
+			continue
+		}
+		p, err := archive.ReadPost(dir, e.Nr)
+		if err != nil {
+			return err
+		}
+		l := newLinks(opts.BaseURL)
+		for _, f := range e.Attachments {
+			if len(f.Path) > 0 {
+				l.add(f.URL, "../"+f.Path)
+				if err := copyFile(filepath.Join(dir, filepath.FromSlash(f.Path)), filepath.Join(out, filepath.FromSlash(f.Path))); err != nil {
+					return err
+				}
+			}
+		}
+		pp, err := newPostPage(base, e, p, l)
+		if err != nil {
+			return errors.Wrapf(err, "rendering post %d", e.Nr)
+		}
+		if err := writeTemplate(filepath.Join(out, filepath.FromSlash(postLink(e.Nr))), "post", pp); err != nil {
+			return err
+		}
+		entries = append(entries, e)
+		index = append(index, searchEntry{
+			Nr:      e.Nr,
+			Subject: e.Subject,
+			Folders: e.Folders,
+			URL:     postLink(e.Nr),
+			Text:    strings.Join(searchText(p, opts.BaseURL), " "),
+		})
+	}
+
+	rp, err := newResourcesPage(base, dir, out, opts.BaseURL, m.Resources)
+	if err != nil {
+		return err
+	}
+	if err := writeTemplate(filepath.Join(out, ResourcesFile), "resources", rp); err != nil {
+		return err
+	}
+	if err := writeSearchIndex(filepath.Join(out, SearchFile), index); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(out, StyleFile), []byte(style), 0644); err != nil {
+		return err
+	}
+	if err := writeTemplate(filepath.Join(out, IndexFile), "index", indexPage{
+		page:    base,
+		Folders: folders(n.Folders, entries),
+	}); err != nil {
+		return err
+	}
+	opts.logf("%s: wrote %d posts and %d resources to %s", m.Network, len(entries), len(m.Resources), out)
+	return nil
+}
+
+// folders groups posts by folder, newest first, with the class's folders in
+// its order followed by any others and then Unfiled.
+func folders(order []string, entries []archive.PostEntry) []folder {
+	byName := map[string][]archive.PostEntry{}
+	var extra []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		names := e.Folders
+		if len(names) == 0 {
+			names = []string{Unfiled}
+		}
+		for _, name := range names {
+			if _, ok := byName[name]; !ok && !contains(order, name) && name != Unfiled {
+				extra = append(extra, name)
+			}
+			byName[name] = append(byName[name], e)
+		}
+	}
+	sort.Strings(extra)
+
+	var out []folder
+	for _, name := range append(append(append([]string{}, order...), extra...), Unfiled) {
+		if posts := byName[name]; len(posts) > 0 {
+			out = append(out, folder{Name: name, Posts: posts})
+			delete(byName, name)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func anonymous(anon string) bool {
+	return len(anon) > 0 && anon != string(piazza.AnonymousNo)
+}
+
+func endorsements(p piazza.Post) []string {
+	var names []string
+	for _, g := range p.TagGood {
+		names = append(names, g.Name)
+	}
+	return names
+}
+
+// newReply renders a post, an answer or a followup along with its replies.
+// Followups and feedback keep their text in Subject rather than in History.
+func newReply(p piazza.Post, l links) (reply, error) {
+	r := reply{
+		Created:      p.Created,
+		Anonymous:    anonymous(p.Anon),
+		Unresolved:   p.Type == string(piazza.Followup) && p.NoAnswer != 0,
+		Endorsements: endorsements(p),
+	}
+	content := p.Subject
+	if len(p.History) > 0 {
+		h := p.History[0]
+		r.Subject = h.Subject
+		content = h.Content
+		r.Anonymous = anonymous(h.Anon)
+		if first := p.History[len(p.History)-1].Created; !first.IsZero() {
+			r.Created = first
+		}
+		if len(p.History) > 1 {
+			r.Edited = h.Created
+		}
+	}
+	var err error
+	if r.Content, err = toHTML(content, l); err != nil {
+		return reply{}, err
+	}
+	for _, child := range p.Children {
+		if child.Type != string(piazza.Followup) && child.Type != string(piazza.Feedback) {
+			continue
+		}
+		cr, err := newReply(child, l)
+		if err != nil {
+			return reply{}, err
+		}
+		r.Replies = append(r.Replies, cr)
+	}
+	return r, nil
+}
+
+func newPostPage(base page, e archive.PostEntry, p piazza.Post, l links) (postPage, error) {
+	base.Root = "../"
+	pp := postPage{page: base, Entry: e, Type: p.Type}
+	var err error
+	if pp.Post, err = newReply(p, l); err != nil {
+		return postPage{}, err
+	}
+	pp.Title = pp.Post.Subject
+	for typ, answer := range map[piazza.ChildType]**reply{
+		piazza.InstructorAnswer: &pp.InstructorAnswer,
+		piazza.StudentAnswer:    &pp.StudentAnswer,
+	} {
+		child, ok := p.Child(typ)
+		if !ok {
+			continue
+		}
+		r, err := newReply(child, l)
+		if err != nil {
+			return postPage{}, err
+		}
+		*answer = &r
+	}
+	return pp, nil
+}
+
+// searchText is the text of a post and its replies.
+func searchText(p piazza.Post, baseURL string) []string {
+	var text []string
+	if len(p.Subject) > 0 {
+		text = append(text, plainText(p.Subject, baseURL))
+	}
+	if len(p.History) > 0 {
+		text = append(text, p.History[0].Subject, plainText(p.History[0].Content, baseURL))
+	}
+	for _, child := range p.Children {
+		text = append(text, searchText(child, baseURL)...)
+	}
+	return text
+}
+
+// newResourcesPage lists the resources by section, copying the files the
+// archive downloaded. Relative links are made absolute against baseURL.
+func newResourcesPage(base page, dir, out, baseURL string, entries []archive.ResourceEntry) (resourcesPage, error) {
+	var resources []piazza.Resource
+	file := filepath.Join(dir, archive.ResourcesDir, archive.ResourcesFile)
+	if err := readJSON(file, &resources); err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return resourcesPage{}, err
+	}
+	byID := map[string]piazza.Resource{}
+	for _, r := range resources {
+		byID[r.ID] = r
+	}
+	titles := map[string]string{}
+	for _, s := range base.Network.Config.ResourceSections {
+		titles[s.Name] = s.Title
+	}
+
+	rp := resourcesPage{page: base}
+	rp.Title = "Resources"
+	sections := map[string]int{}
+	for _, e := range entries {
+		r := resource{Subject: e.Subject, Link: e.URL, Date: byID[e.ID].Config.Date}
+		if strings.HasPrefix(r.Link, "/") && !strings.HasPrefix(r.Link, "//") {
+			r.Link = strings.TrimSuffix(baseURL, "/") + r.Link
+		}
+		if e.File != nil && len(e.File.Path) > 0 {
+			r.Link = e.File.Path
+			if err := copyFile(filepath.Join(dir, filepath.FromSlash(e.File.Path)), filepath.Join(out, filepath.FromSlash(e.File.Path))); err != nil {
+				return resourcesPage{}, err
+			}
+		}
+		name := byID[e.ID].Config.Section
+		i, ok := sections[name]
+		if !ok {
+			title := titles[name]
+			if len(title) == 0 {
+				title = name
+			}
+			if len(title) == 0 {
+				title = "General"
+			}
+			i = len(rp.Sections)
+			sections[name] = i
+			rp.Sections = append(rp.Sections, resourceSection{Title: title})
+		}
+		rp.Sections[i].Resources = append(rp.Sections[i].Resources, r)
+	}
+	return rp, nil
+}
+
+func readJSON(file string, v interface{}) error {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(json.Unmarshal(buf, v), "reading %q", file)
+}
+
+func writeTemplate(file, name string, data interface{}) error {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return errors.Wrapf(err, "rendering %q", file)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, buf.Bytes(), 0644)
+}
+
+// writeSearchIndex writes the index as a script rather than JSON, so the site
+// can be searched when opened from disk where pages can't fetch files.
+func writeSearchIndex(file string, index []searchEntry) error {
+	buf, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, []byte("var searchIndex = "+string(buf)+";\n"), 0644)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
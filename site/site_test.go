@@ -0,0 +1,153 @@
+package site_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/archive"
+	"github.com/d4l3k/piazza-api/piazzatest"
+	"github.com/d4l3k/piazza-api/site"
+)
+
+// writeArchive archives a class from the fake to a temporary directory.
+func writeArchive(t *testing.T) string {
+	f, student, _ := piazzatest.NewClass(t, `{"id": "n1", "name": "Computer Networking", "school_ext": "ubc.ca",
+		"term": "Winter Term 1 2016", "short_number": "cpsc317", "folders": ["hw1", "logistics"]}`)
+	notes := f.AddFile("attach/n1/u1/notes.pdf", []byte("%PDF notes"))
+	f.AddPosts("n1", `{
+		"id": "p1", "nr": 1, "type": "question", "folders": ["hw1"],
+		"created": "2016-09-06T20:32:57Z",
+		"history": [{"subject": "Checksums", "created": "2016-09-06T20:32:57Z",
+			"content": "<p>Is $$a_1 + b_1$$ right? See <a href=\"`+notes+`\">the notes</a>.</p><script>alert(1)</script>"}],
+		"tag_good": [{"id": "u2", "name": "Another Student"}],
+		"children": [
+			{"id": "a1", "type": "i_answer", "history": [{"content": "<md>**Yes**, it is.</md>", "created": "2016-09-07T20:32:57Z"}],
+			 "tag_good": [{"id": "u1", "name": "Some Student"}]},
+			{"id": "a2", "type": "s_answer", "history": [{"content": "<p>I think so.</p>", "created": "2016-09-07T10:00:00Z"}]},
+			{"id": "f1", "type": "followup", "subject": "<p>What about b_2?</p>", "anon": "stud", "no_answer": 1,
+			 "children": [{"id": "f2", "type": "feedback", "subject": "Same thing."}]}
+		]
+	}`, `{
+		"id": "p2", "nr": 2, "type": "note", "created": "2016-09-07T20:32:57Z",
+		"history": [{"subject": "Welcome", "content": "Hi, see <a href=\"/class/n1?cid=1\">@1</a>", "created": "2016-09-07T20:32:57Z"}]
+	}`)
+	var r piazza.Resource
+	r.ID = "r1"
+	r.Subject = "Syllabus"
+	r.Content = f.AddFile("resources/n1/syllabus.pdf", []byte("%PDF syllabus"))
+	r.Config.ResourceType = "file"
+	r.Config.Section = "general"
+	f.AddResource("n1", r)
+
+	dir := t.TempDir()
+	if _, err := archive.Write(context.Background(), student, "n1", dir, archive.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func readFile(t *testing.T, dir, file string) string {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf)
+}
+
+func TestGenerate(t *testing.T) {
+	dir := writeArchive(t)
+	out := t.TempDir()
+	if err := site.Generate(dir, out, site.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	index := readFile(t, out, site.IndexFile)
+	hw1 := strings.Index(index, "<h2>hw1</h2>")
+	unfiled := strings.Index(index, "<h2>"+site.Unfiled+"</h2>")
+	if hw1 < 0 || unfiled < hw1 || strings.Contains(index, "<h2>logistics</h2>") {
+		t.Errorf("index doesn't group posts by folder:\n%s", index)
+	}
+	for _, want := range []string{`href="posts/1.html">@1 Checksums`, `href="posts/2.html">@2 Welcome`, `src="search.js"`} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index is missing %q", want)
+		}
+	}
+
+	post := readFile(t, out, "posts/1.html")
+	for _, want := range []string{
+		"<h1>Checksums</h1>",
+		// Math is left for MathJax rather than read as Markdown.
+		"$$a_1 + b_1$$",
+		`href="../attachments/1/notes.pdf"`,
+		"The instructors&#39; answer",
+		"<strong>Yes</strong>, it is.",
+		"The students&#39; answer",
+		"What about b_2?",
+		"Same thing.",
+		"unresolved",
+		`title="Some Student"`,
+		`title="Another Student"`,
+		site.DefaultMathJaxURL,
+	} {
+		if !strings.Contains(post, want) {
+			t.Errorf("post page is missing %q", want)
+		}
+	}
+	if strings.Contains(post, "<script>alert") {
+		t.Errorf("post page kept a script from the content")
+	}
+	if got := readFile(t, out, "attachments/1/notes.pdf"); got != "%PDF notes" {
+		t.Errorf("attachment = %q", got)
+	}
+
+	resources := readFile(t, out, site.ResourcesFile)
+	if !strings.Contains(resources, `href="resources/r1/syllabus.pdf">Syllabus`) {
+		t.Errorf("resources page doesn't link the syllabus:\n%s", resources)
+	}
+	if got := readFile(t, out, "resources/r1/syllabus.pdf"); got != "%PDF syllabus" {
+		t.Errorf("resource = %q", got)
+	}
+
+	search := readFile(t, out, site.SearchFile)
+	prefix := "var searchIndex = "
+	if !strings.HasPrefix(search, prefix) {
+		t.Fatalf("search index = %q", search)
+	}
+	var entries []struct {
+		Nr   int    `json:"nr"`
+		URL  string `json:"url"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(search, prefix), ";\n")), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].URL != "posts/1.html" || !strings.Contains(entries[0].Text, "Same thing.") {
+		t.Errorf("search index = %+v", entries)
+	}
+}
+
+func TestGenerateBaseURL(t *testing.T) {
+	dir := writeArchive(t)
+	m, err := archive.ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for base, want := range map[string]string{
+		// Links point where the archive was made from by default.
+		"":                           m.BaseURL + "/class/n1?cid=1",
+		"https://piazza.example.com": "https://piazza.example.com/class/n1?cid=1",
+	} {
+		out := t.TempDir()
+		if err := site.Generate(dir, out, site.Options{BaseURL: base}); err != nil {
+			t.Fatal(err)
+		}
+		if post := readFile(t, out, "posts/2.html"); !strings.Contains(post, `href="`+want+`"`) {
+			t.Errorf("BaseURL %q: post page doesn't link %q:\n%s", base, want, post)
+		}
+	}
+}
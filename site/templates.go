@@ -0,0 +1,208 @@
+package site
+
+import (
+	"html/template"
+	"strings"
+
+	piazza "github.com/d4l3k/piazza-api"
+)
+
+var funcs = template.FuncMap{
+	"date": func(t piazza.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format("Jan 2, 2006 15:04 UTC")
+	},
+	"join": strings.Join,
+	// answer pairs an answer with its heading for the answer template.
+	"answer": func(title string, r *reply) interface{} {
+		return struct {
+			Title  string
+			Answer *reply
+		}{title, r}
+	},
+}
+
+var templates = template.Must(template.New("").Funcs(funcs).Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Root}}style.css">
+</head>
+<body>
+<nav>
+<a href="{{.Root}}index.html">{{if .Network.Name}}{{.Network.Name}}{{else}}Posts{{end}}</a>
+<a href="{{.Root}}resources.html">Resources</a>
+</nav>
+<main>
+{{end}}
+
+{{define "footer"}}</main>
+</body>
+</html>
+{{end}}
+
+{{define "index"}}{{template "header" .}}
+<h1>{{.Title}}</h1>
+{{with .Network.Term}}<p class="term">{{.}}</p>{{end}}
+<input id="search" type="search" placeholder="Search posts" autocomplete="off">
+<ul id="results" hidden></ul>
+<div id="folders">
+{{range .Folders}}<section>
+<h2>{{.Name}}</h2>
+<ul>
+{{range .Posts}}<li><a href="posts/{{.Nr}}.html">@{{.Nr}} {{.Subject}}</a>{{if .Deleted}} <span class="badge">deleted</span>{{end}} <span class="date">{{date .LastActivity}}</span></li>
+{{end}}</ul>
+</section>
+{{end}}</div>
+<script src="search.js"></script>
+<script>
+(function() {
+  var input = document.getElementById("search");
+  var results = document.getElementById("results");
+  var folders = document.getElementById("folders");
+  input.addEventListener("input", function() {
+    var terms = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    results.innerHTML = "";
+    results.hidden = terms.length == 0;
+    folders.hidden = terms.length > 0;
+    searchIndex.forEach(function(post) {
+      var text = (post.subject + " " + post.text + " " + post.folders.join(" ")).toLowerCase();
+      if (!terms.every(function(t) { return text.indexOf(t) >= 0; })) {
+        return;
+      }
+      var a = document.createElement("a");
+      a.href = post.url;
+      a.textContent = "@" + post.nr + " " + post.subject;
+      var li = document.createElement("li");
+      li.appendChild(a);
+      results.appendChild(li);
+    });
+  });
+})();
+</script>
+{{template "footer" .}}{{end}}
+
+{{define "endorsements"}}{{if .}}<p class="endorsed" title="{{join . ", "}}">Endorsed by {{len .}}</p>{{end}}{{end}}
+
+{{define "reply"}}<div class="reply{{if .Unresolved}} unresolved{{end}}">
+<p class="meta">{{if .Anonymous}}Anonymous, {{end}}{{date .Created}}{{if .Unresolved}} <span class="badge">unresolved</span>{{end}}</p>
+<div class="content">{{.Content}}</div>
+{{template "endorsements" .Endorsements}}
+{{range .Replies}}{{template "reply" .}}{{end}}
+</div>
+{{end}}
+
+{{define "answer"}}<section class="answer">
+<h2>{{.Title}}</h2>
+<div class="content">{{.Answer.Content}}</div>
+<p class="meta">{{if .Answer.Anonymous}}Anonymous, {{end}}{{if .Answer.Edited.IsZero}}{{date .Answer.Created}}{{else}}updated {{date .Answer.Edited}}{{end}}</p>
+{{template "endorsements" .Answer.Endorsements}}
+</section>
+{{end}}
+
+{{define "post"}}{{template "header" .}}
+{{$post := .Post}}<article>
+<p class="folders">@{{.Entry.Nr}} {{.Type}}{{range .Entry.Folders}} <span class="folder">{{.}}</span>{{end}}{{if .Entry.Deleted}} <span class="badge">deleted from Piazza</span>{{end}}</p>
+<h1>{{$post.Subject}}</h1>
+<div class="content">{{$post.Content}}</div>
+<p class="meta">{{if $post.Anonymous}}Anonymous, {{end}}{{date $post.Created}}{{if not $post.Edited.IsZero}}, updated {{date $post.Edited}}{{end}}</p>
+{{template "endorsements" $post.Endorsements}}
+</article>
+{{with .StudentAnswer}}{{template "answer" (answer "The students' answer" .)}}{{end}}
+{{with .InstructorAnswer}}{{template "answer" (answer "The instructors' answer" .)}}{{end}}
+{{if $post.Replies}}<section class="followups">
+<h2>Followup discussions</h2>
+{{range $post.Replies}}{{template "reply" .}}{{end}}
+</section>{{end}}
+{{if .MathJaxURL}}<script>MathJax = {tex: {inlineMath: [["$$", "$$"]], displayMath: []}};</script>
+<script async src="{{.MathJaxURL}}"></script>{{end}}
+{{template "footer" .}}{{end}}
+
+{{define "resources"}}{{template "header" .}}
+<h1>Resources</h1>
+{{range .Sections}}<section>
+<h2>{{.Title}}</h2>
+<ul>
+{{range .Resources}}<li><a href="{{.Link}}">{{.Subject}}</a>{{with .Date}} <span class="date">{{.}}</span>{{end}}</li>
+{{end}}</ul>
+</section>
+{{else}}<p>This class has no resources.</p>
+{{end}}
+{{template "footer" .}}{{end}}
+`))
+
+const style = `body {
+  font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif;
+  line-height: 1.5;
+  margin: 0;
+  color: #222;
+}
+nav {
+  background: #3e7aab;
+  padding: 0.5em 1em;
+}
+nav a {
+  color: white;
+  margin-right: 1em;
+  text-decoration: none;
+}
+main {
+  max-width: 50em;
+  margin: 0 auto;
+  padding: 1em;
+}
+#search {
+  width: 100%;
+  font-size: 1em;
+  padding: 0.4em;
+}
+.date, .meta, .term {
+  color: #777;
+  font-size: 0.9em;
+}
+.folder, .badge {
+  background: #e8eef4;
+  border-radius: 3px;
+  padding: 0 0.4em;
+  font-size: 0.9em;
+}
+.badge {
+  background: #fbe3c4;
+}
+.answer, .followups {
+  border-top: 1px solid #ddd;
+  margin-top: 1.5em;
+}
+.reply {
+  border-left: 3px solid #ddd;
+  padding-left: 1em;
+  margin: 1em 0;
+}
+.reply.unresolved {
+  border-left-color: #e8a33d;
+}
+.endorsed {
+  color: #3e7aab;
+  font-size: 0.9em;
+}
+pre {
+  background: #f4f4f4;
+  overflow-x: auto;
+  padding: 0.5em;
+}
+img {
+  max-width: 100%;
+}
+table {
+  border-collapse: collapse;
+}
+td, th {
+  border: 1px solid #ddd;
+  padding: 0.2em 0.5em;
+}
+`
@@ -0,0 +1,122 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+)
+
+// PostSummary is a top level post as the query helpers return it.
+type PostSummary struct {
+	ID     string
+	Nr     int
+	Type   string
+	Status string
+	// Subject is from the latest revision.
+	Subject string
+	Folders []string
+	// Author is the UID of whoever wrote the original revision. It's empty
+	// if Piazza didn't say, as for anonymous posts.
+	Author       string
+	Created      piazza.Time
+	Updated      piazza.Time
+	Endorsements int
+}
+
+// summarySelect selects the columns scanned into a PostSummary, from posts p.
+const summarySelect = `
+SELECT p.id, p.nr, p.type, p.status,
+	COALESCE((SELECT subject FROM revisions r WHERE r.network_id = p.network_id AND r.post_id = p.id
+		ORDER BY revision DESC LIMIT 1), ''),
+	COALESCE((SELECT uid FROM revisions r WHERE r.network_id = p.network_id AND r.post_id = p.id
+		AND revision = 1), ''),
+	p.created, p.updated,
+	(SELECT COUNT(*) FROM endorsements e WHERE e.network_id = p.network_id AND e.post_id = p.id)
+FROM posts p
+WHERE p.network_id = ? AND p.parent_id IS NULL AND p.deleted = 0`
+
+// summaries runs a query built on summarySelect and fills in the folders of
+// the posts it finds.
+func (s *Store) summaries(ctx context.Context, nid, where string, args ...interface{}) ([]PostSummary, error) {
+	rows, err := s.db.QueryContext(ctx, summarySelect+where+` ORDER BY p.nr`, append([]interface{}{nid}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var posts []PostSummary
+	for rows.Next() {
+		var p PostSummary
+		var created, updated sql.NullString
+		if err := rows.Scan(&p.ID, &p.Nr, &p.Type, &p.Status, &p.Subject, &p.Author, &created, &updated, &p.Endorsements); err != nil {
+			return nil, err
+		}
+		if p.Created, err = parseTime(created); err != nil {
+			return nil, err
+		}
+		if p.Updated, err = parseTime(updated); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	folders, err := s.postFolders(ctx, nid)
+	if err != nil {
+		return nil, err
+	}
+	for i := range posts {
+		posts[i].Folders = folders[posts[i].ID]
+	}
+	return posts, nil
+}
+
+// postFolders returns the folders of every post in the class, by post ID.
+func (s *Store) postFolders(ctx context.Context, nid string) (map[string][]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT post_id, folder FROM post_folders WHERE network_id = ? ORDER BY post_id, position`, nid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	folders := map[string][]string{}
+	for rows.Next() {
+		var id, folder string
+		if err := rows.Scan(&id, &folder); err != nil {
+			return nil, err
+		}
+		folders[id] = append(folders[id], folder)
+	}
+	return folders, rows.Err()
+}
+
+// PostsByFolder returns the posts of the class nid in a folder, by number.
+func (s *Store) PostsByFolder(ctx context.Context, nid, folder string) ([]PostSummary, error) {
+	return s.summaries(ctx, nid, `
+	AND EXISTS (SELECT 1 FROM post_folders f WHERE f.network_id = p.network_id AND f.post_id = p.id AND f.folder = ?)`,
+		folder)
+}
+
+// PostsByAuthor returns the posts of the class nid that uid wrote, by number.
+// Anonymous posts are only found if Piazza gave their author.
+func (s *Store) PostsByAuthor(ctx context.Context, nid, uid string) ([]PostSummary, error) {
+	return s.summaries(ctx, nid, `
+	AND EXISTS (SELECT 1 FROM revisions r WHERE r.network_id = p.network_id AND r.post_id = p.id
+		AND r.revision = 1 AND r.uid = ?)`,
+		uid)
+}
+
+// UnresolvedSince returns the posts of the class nid with activity since the
+// given time that are unresolved: questions without an answer, or posts with
+// unresolved followups. They're ordered by number.
+func (s *Store) UnresolvedSince(ctx context.Context, nid string, since time.Time) ([]PostSummary, error) {
+	return s.summaries(ctx, nid, `
+	AND p.updated >= ?
+	AND ((p.type = ? AND NOT EXISTS (SELECT 1 FROM posts c WHERE c.network_id = p.network_id AND c.parent_id = p.id
+			AND c.type IN (?, ?)))
+		OR p.no_answer_followup > 0)`,
+		since.UTC().Format(timeLayout), string(piazza.PostQuestion), string(piazza.StudentAnswer), string(piazza.InstructorAnswer))
+}
@@ -0,0 +1,166 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// migrations bring the schema from one version to the next. migrations[i]
+// takes a database at version i to version i+1. Append to the list; never
+// change a migration that has shipped.
+var migrations = []string{
+	`
+CREATE TABLE networks (
+	id            TEXT PRIMARY KEY,
+	name          TEXT NOT NULL,
+	course_number TEXT NOT NULL,
+	term          TEXT NOT NULL,
+	school_ext    TEXT NOT NULL,
+	short_number  TEXT NOT NULL,
+	-- data is the network as network.get returns it.
+	data          TEXT NOT NULL
+);
+
+CREATE TABLE folders (
+	network_id TEXT NOT NULL REFERENCES networks (id) ON DELETE CASCADE,
+	name       TEXT NOT NULL,
+	position   INTEGER NOT NULL,
+	PRIMARY KEY (network_id, name)
+);
+
+CREATE TABLE users (
+	network_id TEXT NOT NULL,
+	id         TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	email      TEXT NOT NULL,
+	role       TEXT NOT NULL,
+	photo      TEXT NOT NULL,
+	admin      INTEGER NOT NULL,
+	PRIMARY KEY (network_id, id)
+);
+
+-- posts holds every post and reply, flattened. Top level posts have no
+-- parent_id and carry the feed state Sync compares against.
+CREATE TABLE posts (
+	network_id         TEXT NOT NULL,
+	id                 TEXT NOT NULL,
+	root_id            TEXT NOT NULL,
+	parent_id          TEXT,
+	position           INTEGER NOT NULL,
+	nr                 INTEGER NOT NULL,
+	type               TEXT NOT NULL,
+	status             TEXT NOT NULL,
+	-- subject is the text of followups and feedback, which have no history.
+	subject            TEXT NOT NULL,
+	uid                TEXT NOT NULL,
+	anon               TEXT NOT NULL,
+	bucket_name        TEXT NOT NULL,
+	created            TEXT,
+	no_answer          INTEGER NOT NULL,
+	no_answer_followup INTEGER NOT NULL,
+	num_favorites      INTEGER NOT NULL,
+	unique_views       INTEGER NOT NULL,
+	main_version       INTEGER,
+	modified           TEXT,
+	updated            TEXT,
+	deleted            INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (network_id, id),
+	FOREIGN KEY (network_id, parent_id) REFERENCES posts (network_id, id) ON DELETE CASCADE
+);
+CREATE INDEX posts_root ON posts (network_id, root_id);
+CREATE INDEX posts_parent ON posts (network_id, parent_id);
+CREATE INDEX posts_updated ON posts (network_id, updated);
+
+CREATE TABLE post_folders (
+	network_id TEXT NOT NULL,
+	post_id    TEXT NOT NULL,
+	folder     TEXT NOT NULL,
+	position   INTEGER NOT NULL,
+	PRIMARY KEY (network_id, post_id, folder),
+	FOREIGN KEY (network_id, post_id) REFERENCES posts (network_id, id) ON DELETE CASCADE
+);
+CREATE INDEX post_folders_folder ON post_folders (network_id, folder);
+
+-- revisions are the entries of a post's history, numbered from 1 for the
+-- original.
+CREATE TABLE revisions (
+	network_id TEXT NOT NULL,
+	post_id    TEXT NOT NULL,
+	revision   INTEGER NOT NULL,
+	subject    TEXT NOT NULL,
+	content    TEXT NOT NULL,
+	uid        TEXT NOT NULL,
+	anon       TEXT NOT NULL,
+	created    TEXT,
+	PRIMARY KEY (network_id, post_id, revision),
+	FOREIGN KEY (network_id, post_id) REFERENCES posts (network_id, id) ON DELETE CASCADE
+);
+CREATE INDEX revisions_uid ON revisions (network_id, uid);
+
+CREATE TABLE change_log (
+	network_id TEXT NOT NULL,
+	post_id    TEXT NOT NULL,
+	seq        INTEGER NOT NULL,
+	type       TEXT NOT NULL,
+	data       TEXT NOT NULL,
+	uid        TEXT NOT NULL,
+	anon       TEXT NOT NULL,
+	at         TEXT,
+	PRIMARY KEY (network_id, post_id, seq),
+	FOREIGN KEY (network_id, post_id) REFERENCES posts (network_id, id) ON DELETE CASCADE
+);
+
+-- endorsements are a post's tag_good: the users who marked it good.
+CREATE TABLE endorsements (
+	network_id TEXT NOT NULL,
+	post_id    TEXT NOT NULL,
+	user_id    TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	role       TEXT NOT NULL,
+	admin      INTEGER NOT NULL,
+	PRIMARY KEY (network_id, post_id, user_id),
+	FOREIGN KEY (network_id, post_id) REFERENCES posts (network_id, id) ON DELETE CASCADE
+);
+`,
+}
+
+// version returns the schema version of db, 0 if it's empty.
+func version(ctx context.Context, db *sql.DB) (int, error) {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return 0, errors.Wrap(err, "creating schema_version")
+	}
+	var v int
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&v)
+	return v, errors.Wrap(err, "reading schema version")
+}
+
+// migrate runs the migrations db hasn't had yet, each in its own transaction.
+func migrate(ctx context.Context, db *sql.DB) error {
+	v, err := version(ctx, db)
+	if err != nil {
+		return err
+	}
+	if v > len(migrations) {
+		return errors.Errorf("database has schema version %d; only up to %d is supported", v, len(migrations))
+	}
+	for ; v < len(migrations); v++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[v]); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "migrating to version %d", v+1)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_version (version) VALUES (?)`, v+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return errors.Wrapf(err, "migrating to version %d", v+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+// Package sqlite keeps classes in a SQLite database: the network, its folders
+// and users, and every post flattened into its replies, with each revision of
+// their history, their change logs and endorsements. Store is a piazza.Store,
+// so Client.Sync keeps its posts up to date incrementally, and Store.Sync does
+// the same for the class and its members too:
+//
+//	s, err := sqlite.Open(ctx, "piazza.db")
+//	...
+//	_, err = s.Sync(ctx, c, nid, piazza.SyncOptions{})
+//
+// The schema is created and migrated when the database is opened.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/pkg/errors"
+
+	// Registers the sqlite3 driver Open uses.
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Store is a class store backed by a SQLite database. Use Open or New to
+// create one.
+type Store struct {
+	db *sql.DB
+	// mu serializes writes, since SQLite only allows one writer at a time.
+	mu sync.Mutex
+}
+
+var _ piazza.Store = (*Store)(nil)
+
+// Open opens the database in file, creating it if needed. Close the store
+// when finished with it.
+func Open(ctx context.Context, file string) (*Store, error) {
+	db, err := sql.Open("sqlite3", "file:"+file+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	// A single connection keeps in-memory databases from being one per
+	// connection and avoids lock contention between them.
+	db.SetMaxOpenConns(1)
+	s, err := New(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// New returns a store using db, which must be a SQLite database, and brings
+// its schema up to date.
+func New(ctx context.Context, db *sql.DB) (*Store, error) {
+	if err := migrate(ctx, db); err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// DB returns the underlying database, for queries the store doesn't offer.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// timeLayout is how times are stored. It's fixed width and always UTC so
+// stored times sort as text.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+func timeValue(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(timeLayout)
+}
+
+func parseTime(s sql.NullString) (piazza.Time, error) {
+	if !s.Valid {
+		return piazza.Time{}, nil
+	}
+	t, err := time.Parse(timeLayout, s.String)
+	return piazza.Time{Time: t}, errors.Wrapf(err, "parsing stored time %q", s.String)
+}
+
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// withTx runs f in a transaction, holding the write lock.
+func (s *Store) withTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// PutNetwork saves a class and its folders.
+func (s *Store) PutNetwork(ctx context.Context, n piazza.Network) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO networks (id, name, course_number, term, school_ext, short_number, data)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET
+	name = excluded.name,
+	course_number = excluded.course_number,
+	term = excluded.term,
+	school_ext = excluded.school_ext,
+	short_number = excluded.short_number,
+	data = excluded.data`,
+			n.ID, n.Name, n.CourseNumber, n.Term, n.SchoolExt, n.ShortNumber, string(data)); err != nil {
+			return errors.Wrapf(err, "saving network %q", n.ID)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM folders WHERE network_id = ?`, n.ID); err != nil {
+			return err
+		}
+		for i, f := range n.Folders {
+			if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO folders (network_id, name, position) VALUES (?, ?, ?)`,
+				n.ID, f, i); err != nil {
+				return errors.Wrapf(err, "saving folder %q", f)
+			}
+		}
+		return nil
+	})
+}
+
+// Network returns a saved class. It returns sql.ErrNoRows if there isn't one.
+func (s *Store) Network(ctx context.Context, nid string) (piazza.Network, error) {
+	var data string
+	if err := s.db.QueryRowContext(ctx, `SELECT data FROM networks WHERE id = ?`, nid).Scan(&data); err != nil {
+		return piazza.Network{}, err
+	}
+	var n piazza.Network
+	err := json.Unmarshal([]byte(data), &n)
+	return n, errors.Wrapf(err, "reading network %q", nid)
+}
+
+// Folders returns the folders of a saved class in the order Piazza lists them.
+func (s *Store) Folders(ctx context.Context, nid string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name FROM folders WHERE network_id = ? ORDER BY position`, nid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var folders []string
+	for rows.Next() {
+		var f string
+		if err := rows.Scan(&f); err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+	return folders, rows.Err()
+}
+
+// PutUsers saves members of the class nid, such as those Client.Roster or
+// Client.Users return. Users that were saved before are updated.
+func (s *Store) PutUsers(ctx context.Context, nid string, users []piazza.User) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		for _, u := range users {
+			if _, err := tx.ExecContext(ctx, `
+INSERT OR REPLACE INTO users (network_id, id, name, email, role, photo, admin)
+VALUES (?, ?, ?, ?, ?, ?, ?)`,
+				nid, u.ID, u.Name, u.Email, u.Role, u.Photo, boolValue(u.Admin)); err != nil {
+				return errors.Wrapf(err, "saving user %q", u.ID)
+			}
+		}
+		return nil
+	})
+}
+
+// Users returns the saved members of the class nid, by ID.
+func (s *Store) Users(ctx context.Context, nid string) (map[string]piazza.User, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, email, role, photo, admin FROM users WHERE network_id = ?`, nid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := map[string]piazza.User{}
+	for rows.Next() {
+		var u piazza.User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.Photo, &u.Admin); err != nil {
+			return nil, err
+		}
+		users[u.ID] = u
+	}
+	return users, rows.Err()
+}
+
+// PostStates implements piazza.Store. Deleted posts are left out.
+func (s *Store) PostStates(ctx context.Context, nid string) (map[string]piazza.PostState, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id, nr, main_version, modified, updated FROM posts
+WHERE network_id = ? AND parent_id IS NULL AND deleted = 0`, nid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	states := map[string]piazza.PostState{}
+	for rows.Next() {
+		var state piazza.PostState
+		var version sql.NullInt64
+		var modified, updated sql.NullString
+		if err := rows.Scan(&state.ID, &state.Nr, &version, &modified, &updated); err != nil {
+			return nil, err
+		}
+		state.MainVersion = int(version.Int64)
+		if state.Modified, err = parseTime(modified); err != nil {
+			return nil, err
+		}
+		if state.Updated, err = parseTime(updated); err != nil {
+			return nil, err
+		}
+		states[state.ID] = state
+	}
+	return states, rows.Err()
+}
+
+// PutPost implements piazza.Store. It replaces everything saved about the
+// post and its replies.
+func (s *Store) PutPost(ctx context.Context, nid string, item piazza.FeedItem, post piazza.Post) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := deleteTree(ctx, tx, nid, post.ID); err != nil {
+			return err
+		}
+		if err := putPost(ctx, tx, nid, post.ID, "", 0, post.Nr, post); err != nil {
+			return errors.Wrapf(err, "saving post %q", post.ID)
+		}
+		state := item.State()
+		_, err := tx.ExecContext(ctx, `
+UPDATE posts SET main_version = ?, modified = ?, updated = ?
+WHERE network_id = ? AND id = ?`,
+			state.MainVersion, timeValue(state.Modified.Time), timeValue(state.Updated.Time), nid, post.ID)
+		return err
+	})
+}
+
+// DeletePost implements piazza.Store. The post is kept, marked as deleted, and
+// left out of queries.
+func (s *Store) DeletePost(ctx context.Context, nid string, state piazza.PostState) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `UPDATE posts SET deleted = 1 WHERE network_id = ? AND root_id = ?`, nid, state.ID)
+		return errors.Wrapf(err, "deleting post %q", state.ID)
+	})
+}
+
+// deleteTree removes a post and its replies.
+func deleteTree(ctx context.Context, tx *sql.Tx, nid, rootID string) error {
+	for _, table := range []string{"post_folders", "revisions", "change_log", "endorsements"} {
+		if _, err := tx.ExecContext(ctx, `
+DELETE FROM `+table+` WHERE network_id = ? AND post_id IN (
+	SELECT id FROM posts WHERE network_id = ? AND root_id = ?)`, nid, nid, rootID); err != nil {
+			return errors.Wrapf(err, "deleting from %s", table)
+		}
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE network_id = ? AND root_id = ?`, nid, rootID)
+	return err
+}
+
+// putPost inserts p and, recursively, its replies. Replies share the number of
+// the post they belong to.
+func putPost(ctx context.Context, tx *sql.Tx, nid, rootID, parentID string, position, nr int, p piazza.Post) error {
+	var parent interface{}
+	if len(parentID) > 0 {
+		parent = parentID
+	}
+	if _, err := tx.ExecContext(ctx, `
+INSERT INTO posts (network_id, id, root_id, parent_id, position, nr, type, status, subject, uid, anon,
+	bucket_name, created, no_answer, no_answer_followup, num_favorites, unique_views)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		nid, p.ID, rootID, parent, position, nr, p.Type, p.Status, p.Subject, p.UID, p.Anon,
+		p.BucketName, timeValue(p.Created.Time), p.NoAnswer, p.NoAnswerFollowup, p.NumFavorites, p.UniqueViews); err != nil {
+		return err
+	}
+
+	for i, f := range p.Folders {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO post_folders (network_id, post_id, folder, position) VALUES (?, ?, ?, ?)`,
+			nid, p.ID, f, i); err != nil {
+			return err
+		}
+	}
+	// History is newest first; revision 1 is the original.
+	for i, h := range p.History {
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO revisions (network_id, post_id, revision, subject, content, uid, anon, created)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			nid, p.ID, len(p.History)-i, h.Subject, h.Content, h.UID, h.Anon, timeValue(h.Created.Time)); err != nil {
+			return err
+		}
+	}
+	for i, c := range p.ChangeLog {
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO change_log (network_id, post_id, seq, type, data, uid, anon, at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			nid, p.ID, i, c.Type, c.Data, c.UID, c.Anon, timeValue(c.When.Time)); err != nil {
+			return err
+		}
+	}
+	for _, g := range p.TagGood {
+		if _, err := tx.ExecContext(ctx, `
+INSERT OR IGNORE INTO endorsements (network_id, post_id, user_id, name, role, admin)
+VALUES (?, ?, ?, ?, ?, ?)`,
+			nid, p.ID, g.ID, g.Name, g.Role, boolValue(g.Admin)); err != nil {
+			return err
+		}
+	}
+
+	for i, child := range p.Children {
+		if err := putPost(ctx, tx, nid, rootID, p.ID, i, nr, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/piazzatest"
+	"github.com/d4l3k/piazza-api/store/sqlite"
+)
+
+// newFake returns a client for the instructor of class n1, which has two
+// questions, one answered, and a note.
+func newFake(t *testing.T) *piazza.Client {
+	_, _, instructor := piazzatest.NewClass(t, `{
+		"id": "n1",
+		"name": "Computer Networking",
+		"short_number": "cpsc317",
+		"folders": ["hw1", "logistics"],
+		"config": {"roles": {"instructor": {"question_edit": true, "question_delete": true}}}
+	}`, `{
+		"id": "p1", "nr": 1, "type": "question", "folders": ["hw1"],
+		"created": "2016-09-06T20:32:57Z",
+		"history": [
+			{"subject": "Ports?", "content": "Which ports?", "uid": "u1", "created": "2016-09-06T21:00:00Z"},
+			{"subject": "Port", "content": "Which port?", "uid": "u1", "created": "2016-09-06T20:32:57Z"}
+		],
+		"change_log": [
+			{"type": "create", "uid": "u1", "when": "2016-09-06T20:32:57Z"},
+			{"type": "i_answer", "uid": "i1", "when": "2016-09-07T10:00:00Z"}
+		],
+		"children": [{
+			"id": "a1", "type": "i_answer", "created": "2016-09-07T10:00:00Z",
+			"history": [{"content": "Any of them.", "uid": "i1", "created": "2016-09-07T10:00:00Z"}],
+			"tag_good": [{"id": "u1", "name": "Some Student", "role": "student"}],
+			"children": [{"id": "f1", "type": "followup", "subject": "Thanks!", "uid": "u1"}]
+		}]
+	}`, `{
+		"id": "p2", "nr": 2, "type": "question", "folders": ["logistics"],
+		"created": "2016-09-08T20:32:57Z",
+		"history": [{"subject": "Office hours", "content": "When?", "uid": "u1", "created": "2016-09-08T20:32:57Z"}],
+		"change_log": [{"type": "create", "uid": "u1", "when": "2016-09-08T20:32:57Z"}]
+	}`, `{
+		"id": "p3", "nr": 3, "type": "note", "folders": ["hw1", "logistics"],
+		"created": "2016-09-09T20:32:57Z",
+		"history": [{"subject": "HW1 is out", "uid": "i1", "created": "2016-09-09T20:32:57Z"}],
+		"change_log": [{"type": "create", "uid": "i1", "when": "2016-09-09T20:32:57Z"}]
+	}`)
+	return instructor
+}
+
+func count(t *testing.T, s *sqlite.Store, query string, args ...interface{}) int {
+	var n int
+	if err := s.DB().QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func ids(posts []sqlite.PostSummary) []string {
+	var ids []string
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func TestStore(t *testing.T) {
+	c := newFake(t)
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "piazza.db")
+	s, err := sqlite.Open(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	summary, err := s.Sync(ctx, c, "n1", piazza.SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.New) != 3 {
+		t.Fatalf("Sync() = %+v; expected 3 new posts", summary)
+	}
+
+	if got, err := s.Network(ctx, "n1"); err != nil || got.ShortNumber != "cpsc317" {
+		t.Errorf("Network() = %+v, %v", got, err)
+	}
+	if got, err := s.Folders(ctx, "n1"); err != nil || len(got) != 2 || got[0] != "hw1" {
+		t.Errorf("Folders() = %v, %v", got, err)
+	}
+	// The instructor can't see the roster, so the members are the authors.
+	if got, err := s.Users(ctx, "n1"); err != nil || len(got) != 2 || got["u1"].Role != "student" || got["i1"].Role != "instructor" {
+		t.Errorf("Users() = %+v, %v", got, err)
+	}
+	for query, want := range map[string]int{
+		`SELECT COUNT(*) FROM posts`:                                                                5,
+		`SELECT COUNT(*) FROM posts WHERE root_id = 'p1' AND nr = 1`:                                3,
+		`SELECT COUNT(*) FROM posts WHERE id = 'f1' AND parent_id = 'a1'`:                           1,
+		`SELECT COUNT(*) FROM revisions WHERE post_id = 'p1'`:                                       2,
+		`SELECT COUNT(*) FROM revisions WHERE post_id = 'p1' AND revision = 1 AND subject = 'Port'`: 1,
+		`SELECT COUNT(*) FROM change_log`:                                                           4,
+		`SELECT COUNT(*) FROM endorsements WHERE post_id = 'a1'`:                                    1,
+	} {
+		if got := count(t, s, query); got != want {
+			t.Errorf("%s = %d; not %d", query, got, want)
+		}
+	}
+
+	posts, err := s.PostsByFolder(ctx, "n1", "hw1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(posts); len(got) != 2 || got[0] != "p1" || got[1] != "p3" {
+		t.Errorf("PostsByFolder(hw1) = %+v", posts)
+	}
+	if p := posts[0]; p.Subject != "Ports?" || p.Author != "u1" || len(p.Folders) != 1 || p.Nr != 1 {
+		t.Errorf("summary = %+v", p)
+	}
+	if posts, err := s.PostsByAuthor(ctx, "n1", "u1"); err != nil || len(posts) != 2 {
+		t.Errorf("PostsByAuthor(u1) = %+v, %v", posts, err)
+	}
+	if posts, err := s.UnresolvedSince(ctx, "n1", time.Time{}); err != nil || len(ids(posts)) != 1 || posts[0].ID != "p2" {
+		t.Errorf("UnresolvedSince(zero) = %+v, %v", posts, err)
+	}
+	if posts, err := s.UnresolvedSince(ctx, "n1", time.Date(2016, 9, 9, 0, 0, 0, 0, time.UTC)); err != nil || len(posts) != 0 {
+		t.Errorf("UnresolvedSince(later) = %+v, %v", posts, err)
+	}
+
+	if _, err := c.EditPost(ctx, "n1", "p2", "Office hours?", "When are they?"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeletePost(ctx, "n1", "p3"); err != nil {
+		t.Fatal(err)
+	}
+	summary, err = c.Sync(ctx, "n1", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Updated) != 1 || len(summary.Deleted) != 1 || summary.Unchanged != 1 {
+		t.Fatalf("Sync() after changes = %+v", summary)
+	}
+	if got := count(t, s, `SELECT COUNT(*) FROM revisions WHERE post_id = 'p2'`); got != 2 {
+		t.Errorf("p2 has %d revisions; expected 2", got)
+	}
+	if posts, err := s.PostsByFolder(ctx, "n1", "hw1"); err != nil || len(posts) != 1 {
+		t.Errorf("PostsByFolder(hw1) after delete = %+v, %v", posts, err)
+	}
+	if got := count(t, s, `SELECT COUNT(*) FROM posts WHERE id = 'p3' AND deleted = 1`); got != 1 {
+		t.Errorf("deleted post wasn't kept")
+	}
+	s.Close()
+
+	// Reopening shouldn't migrate again or lose anything.
+	s, err = sqlite.Open(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	summary, err = c.Sync(ctx, "n1", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Unchanged != 2 || len(summary.New)+len(summary.Updated)+len(summary.Deleted) != 0 {
+		t.Errorf("Sync() after reopening = %+v; expected nothing to change", summary)
+	}
+}
@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"context"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/pkg/errors"
+)
+
+// Sync brings the store up to date with the class nid: the class and its
+// folders, its posts through Client.Sync, and its members. Members come from
+// the roster if the user may see it, and otherwise are the authors of the
+// saved posts that Piazza names.
+func (s *Store) Sync(ctx context.Context, c *piazza.Client, nid string, opts piazza.SyncOptions) (piazza.SyncSummary, error) {
+	n, err := c.Network(ctx, nid)
+	if err != nil {
+		return piazza.SyncSummary{}, err
+	}
+	if err := s.PutNetwork(ctx, n); err != nil {
+		return piazza.SyncSummary{}, err
+	}
+	summary, err := c.SyncWithOptions(ctx, nid, s, opts)
+	if err != nil {
+		return piazza.SyncSummary{}, err
+	}
+
+	users, err := c.Roster(ctx, nid)
+	if errors.Is(err, piazza.ErrPermissionDenied) {
+		var uids []string
+		if uids, err = s.authors(ctx, nid); err == nil && len(uids) > 0 {
+			users, err = c.Users(ctx, nid, uids...)
+		}
+	}
+	if err != nil {
+		return piazza.SyncSummary{}, err
+	}
+	if err := s.PutUsers(ctx, nid, users); err != nil {
+		return piazza.SyncSummary{}, err
+	}
+	return summary, nil
+}
+
+// authors returns the IDs of everyone named as writing or changing a saved
+// post of the class nid.
+func (s *Store) authors(ctx context.Context, nid string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT uid FROM posts WHERE network_id = ? AND uid != ''
+UNION SELECT uid FROM revisions WHERE network_id = ? AND uid != ''
+UNION SELECT uid FROM change_log WHERE network_id = ? AND uid != ''
+ORDER BY uid`, nid, nid, nid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			return nil, err
+		}
+		uids = append(uids, uid)
+	}
+	return uids, rows.Err()
+}
@@ -0,0 +1,209 @@
+package piazza
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// DefaultSyncConcurrency is the number of posts Sync fetches at once.
+const DefaultSyncConcurrency = 4
+
+// PostState is what a Store remembers about a saved post, enough to tell from
+// the feed whether it has changed since.
+type PostState struct {
+	ID          string `json:"id"`
+	Nr          int    `json:"nr"`
+	MainVersion int    `json:"main_version"`
+	Modified    Time   `json:"modified"`
+	Updated     Time   `json:"updated"`
+}
+
+// State returns the state of the post the item summarizes.
+func (f FeedItem) State() PostState {
+	return PostState{
+		ID:          f.ID,
+		Nr:          f.Nr,
+		MainVersion: f.MainVersion,
+		Modified:    f.Modified,
+		Updated:     f.Updated,
+	}
+}
+
+// changed reports whether the feed shows changes since the post was saved.
+func (s PostState) changed(item FeedItem) bool {
+	return item.MainVersion != s.MainVersion ||
+		item.Modified.After(s.Modified.Time) ||
+		item.Updated.After(s.Updated.Time)
+}
+
+// Store is where Sync keeps the posts of a class. PutPost may be called from
+// several goroutines at once.
+type Store interface {
+	// PostStates returns the state of every post the store holds for the
+	// class, by post ID.
+	PostStates(ctx context.Context, nid string) (map[string]PostState, error)
+	// PutPost saves a new or changed post along with the feed item it was
+	// fetched for, whose State the store should remember.
+	PutPost(ctx context.Context, nid string, item FeedItem, post Post) error
+	// DeletePost records that a post is no longer in the class feed.
+	DeletePost(ctx context.Context, nid string, state PostState) error
+}
+
+// SyncOptions controls SyncWithOptions.
+type SyncOptions struct {
+	// Concurrency is how many posts are fetched at once. It defaults to
+	// DefaultSyncConcurrency.
+	Concurrency int
+	// Force fetches every post, changed or not.
+	Force bool
+}
+
+// SyncSummary is what Sync did. Posts are ordered by number.
+type SyncSummary struct {
+	New       []PostState
+	Updated   []PostState
+	Deleted   []PostState
+	Unchanged int
+}
+
+// Sync brings store up to date with the class nid. The feed is compared with
+// what the store holds and only new or changed posts are fetched. Posts that
+// are no longer in the feed, and that Piazza confirms are gone, are deleted
+// from the store.
+func (c *Client) Sync(ctx context.Context, nid string, store Store) (SyncSummary, error) {
+	return c.SyncWithOptions(ctx, nid, store, SyncOptions{})
+}
+
+// SyncWithOptions is like Sync but with options.
+func (c *Client) SyncWithOptions(ctx context.Context, nid string, store Store, opts SyncOptions) (SyncSummary, error) {
+	states, err := store.PostStates(ctx, nid)
+	if err != nil {
+		return SyncSummary{}, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	var firstErr error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultSyncConcurrency
+	}
+	// gone holds the posts deleted between listing and fetching them.
+	gone := map[string]bool{}
+	items := make(chan FeedItem)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				post, err := c.ContentContext(ctx, nid, item.ID)
+				if isKind(err, ErrNotFound) {
+					mu.Lock()
+					gone[item.ID] = true
+					mu.Unlock()
+					continue
+				}
+				if err == nil {
+					err = store.PutPost(ctx, nid, item, post)
+				}
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	var summary SyncSummary
+	seen := map[string]bool{}
+	it := c.FeedPages(ctx, nid, FeedOptions{Sort: SortCreated})
+	for it.Next() {
+		item := it.Item()
+		seen[item.ID] = true
+		state, ok := states[item.ID]
+		switch {
+		case !ok:
+			summary.New = append(summary.New, item.State())
+		case opts.Force || state.changed(item):
+			summary.Updated = append(summary.Updated, item.State())
+		default:
+			summary.Unchanged++
+			continue
+		}
+		select {
+		case items <- item:
+		case <-ctx.Done():
+		}
+	}
+	close(items)
+	wg.Wait()
+	if firstErr != nil {
+		return SyncSummary{}, firstErr
+	}
+	if err := it.Err(); err != nil {
+		return SyncSummary{}, err
+	}
+
+	summary.New = withoutGone(summary.New, gone)
+	summary.Updated = withoutGone(summary.Updated, gone)
+
+	// Only a complete walk of the feed shows what might be gone, and a post
+	// is only deleted once Piazza says it can't be found.
+	for id, state := range states {
+		if seen[id] && !gone[id] {
+			continue
+		}
+		if !gone[id] {
+			deleted, err := c.PostDeleted(ctx, nid, id)
+			if err != nil {
+				return SyncSummary{}, err
+			}
+			if !deleted {
+				summary.Unchanged++
+				continue
+			}
+		}
+		if err := store.DeletePost(ctx, nid, state); err != nil {
+			return SyncSummary{}, err
+		}
+		summary.Deleted = append(summary.Deleted, state)
+	}
+
+	for _, states := range [][]PostState{summary.New, summary.Updated, summary.Deleted} {
+		sort.Slice(states, func(i, j int) bool { return states[i].Nr < states[j].Nr })
+	}
+	return summary, nil
+}
+
+// withoutGone drops the posts in gone from states.
+func withoutGone(states []PostState, gone map[string]bool) []PostState {
+	var kept []PostState
+	for _, s := range states {
+		if !gone[s.ID] {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// PostDeleted reports whether Piazza confirms that the post cid is gone from
+// the class nid. A post missing from a feed isn't necessarily deleted; the
+// feed may have changed while it was being read.
+func (c *Client) PostDeleted(ctx context.Context, nid, cid string) (bool, error) {
+	_, err := c.ContentContext(ctx, nid, cid)
+	if isKind(err, ErrNotFound) {
+		return true, nil
+	}
+	return false, err
+}
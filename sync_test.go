@@ -0,0 +1,166 @@
+package piazza_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	piazza "github.com/d4l3k/piazza-api"
+)
+
+// memStore is a piazza.Store that counts the posts it's given.
+type memStore struct {
+	mu     sync.Mutex
+	states map[string]piazza.PostState
+	posts  map[string]piazza.Post
+	puts   int
+}
+
+func newMemStore() *memStore {
+	return &memStore{states: map[string]piazza.PostState{}, posts: map[string]piazza.Post{}}
+}
+
+func (s *memStore) PostStates(ctx context.Context, nid string) (map[string]piazza.PostState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := map[string]piazza.PostState{}
+	for id, state := range s.states {
+		states[id] = state
+	}
+	return states, nil
+}
+
+func (s *memStore) PutPost(ctx context.Context, nid string, item piazza.FeedItem, post piazza.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[item.ID] = item.State()
+	s.posts[item.ID] = post
+	s.puts++
+	return nil
+}
+
+func (s *memStore) DeletePost(ctx context.Context, nid string, state piazza.PostState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, state.ID)
+	return nil
+}
+
+func ids(states []piazza.PostState) []string {
+	var ids []string
+	for _, s := range states {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func TestSync(t *testing.T) {
+	s, student := newFake(t)
+	instructor := addModeratedClass(t, s)
+	ctx := context.Background()
+	store := newMemStore()
+
+	summary, err := student.Sync(ctx, "n2", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(summary.New); len(got) != 2 || got[0] != "q1" || got[1] != "q2" || summary.Unchanged != 0 {
+		t.Fatalf("first Sync() = %+v; expected q1 and q2 to be new", summary)
+	}
+	if store.puts != 2 || store.posts["q1"].ID != "q1" {
+		t.Errorf("store has %d puts, %+v", store.puts, store.posts)
+	}
+
+	summary, err = student.Sync(ctx, "n2", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Unchanged != 2 || len(summary.New)+len(summary.Updated)+len(summary.Deleted) != 0 || store.puts != 2 {
+		t.Fatalf("second Sync() = %+v with %d puts; expected nothing to change", summary, store.puts)
+	}
+
+	if _, err := student.AddFollowup(ctx, "n2", "q1", "any news?"); err != nil {
+		t.Fatal(err)
+	}
+	if err := instructor.DeletePost(ctx, "n2", "q2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := instructor.CreatePost(ctx, "n2", piazza.NewPost{Type: piazza.PostNote, Subject: "Exam"}); err != nil {
+		t.Fatal(err)
+	}
+	summary, err = student.Sync(ctx, "n2", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(summary.Updated); len(got) != 1 || got[0] != "q1" {
+		t.Errorf("Updated = %v; expected q1", got)
+	}
+	if got := ids(summary.Deleted); len(got) != 1 || got[0] != "q2" {
+		t.Errorf("Deleted = %v; expected q2", got)
+	}
+	if len(summary.New) != 1 || summary.New[0].Nr != 3 || summary.Unchanged != 0 {
+		t.Errorf("third Sync() = %+v", summary)
+	}
+	if len(store.posts["q1"].Children) != 1 {
+		t.Errorf("q1 wasn't refetched with its followup: %+v", store.posts["q1"])
+	}
+
+	summary, err = student.SyncWithOptions(ctx, "n2", store, piazza.SyncOptions{Force: true, Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Updated) != 2 || summary.Unchanged != 0 {
+		t.Errorf("forced Sync() = %+v; expected everything to be refetched", summary)
+	}
+}
+
+func TestSyncChangesWhileListing(t *testing.T) {
+	s, student := newFake(t)
+	instructor := addModeratedClass(t, s)
+	ctx := context.Background()
+	store := newMemStore()
+	if _, err := student.Sync(ctx, "n2", store); err != nil {
+		t.Fatal(err)
+	}
+
+	// q2 is bumped to the top of the feed by update time between the first
+	// and second page.
+	s.SetFeedPageLimit(1)
+	pages := 0
+	s.OnCall("network.get_my_feed", func() {
+		if pages++; pages == 2 {
+			if _, err := student.AddFollowup(ctx, "n2", "q2", "bump"); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	summary, err := student.Sync(ctx, "n2", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(summary.Updated); len(summary.Deleted) != 0 || len(got) != 1 || got[0] != "q2" {
+		t.Errorf("Sync() = %+v; expected q2 to be updated and nothing deleted", summary)
+	}
+	s.OnCall("network.get_my_feed", nil)
+
+	// q1 is deleted after it's listed but before it's fetched.
+	if _, err := student.AddFollowup(ctx, "n2", "q1", "any news?"); err != nil {
+		t.Fatal(err)
+	}
+	s.OnCall("content.get", func() {
+		s.OnCall("content.get", nil)
+		if err := instructor.DeletePost(ctx, "n2", "q1"); err != nil {
+			t.Error(err)
+		}
+	})
+	summary, err = student.Sync(ctx, "n2", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(summary.Deleted); len(summary.Updated) != 0 || len(got) != 1 || got[0] != "q1" {
+		t.Errorf("Sync() = %+v; expected q1 to be deleted", summary)
+	}
+	if _, ok := store.states["q1"]; ok {
+		t.Errorf("q1 is still in the store")
+	}
+}
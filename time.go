@@ -0,0 +1,79 @@
+package piazza
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Time is a timestamp as Piazza sends it. Depending on the field that's an
+// RFC 3339 string like "2016-09-06T20:32:57Z", a date like "2016-09-06", a
+// number of seconds (or milliseconds) since the epoch, or an empty string,
+// null or 0 for no time at all, which leaves the zero Time.
+//
+// Times are marshaled as RFC 3339 strings in UTC, or "" if zero.
+type Time struct {
+	time.Time
+}
+
+// Epoch values past this are taken to be in milliseconds; as seconds they'd be
+// more than 30000 years from now.
+const maxEpochSeconds = 1e12
+
+func epochTime(n float64) Time {
+	if n == 0 {
+		return Time{}
+	}
+	if n >= maxEpochSeconds {
+		return Time{time.Unix(0, int64(n)*int64(time.Millisecond)).UTC()}
+	}
+	return Time{time.Unix(int64(n), 0).UTC()}
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Time) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		*t = Time{}
+		return nil
+	}
+	if len(b) > 0 && b[0] != '"' {
+		n, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return errors.Errorf("piazza: bad time %s", b)
+		}
+		*t = epochTime(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if len(s) == 0 {
+		*t = Time{}
+		return nil
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		*t = epochTime(n)
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			*t = Time{parsed}
+			return nil
+		}
+	}
+	return errors.Errorf("piazza: bad time %q", s)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte(`""`), nil
+	}
+	return json.Marshal(t.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,51 @@
+package piazza_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+)
+
+func TestTime(t *testing.T) {
+	sept6 := time.Date(2016, 9, 6, 20, 32, 57, 0, time.UTC)
+	for _, tc := range []struct {
+		in   string
+		want time.Time
+	}{
+		{`"2016-09-06T20:32:57Z"`, sept6},
+		{`1473193977`, sept6},
+		{`"1473193977"`, sept6},
+		{`1473193977000`, sept6},
+		{`"2016-09-06"`, time.Date(2016, 9, 6, 0, 0, 0, 0, time.UTC)},
+		{`""`, time.Time{}},
+		{`null`, time.Time{}},
+		{`0`, time.Time{}},
+		{`"0"`, time.Time{}},
+	} {
+		var got piazza.Time
+		if err := json.Unmarshal([]byte(tc.in), &got); err != nil {
+			t.Errorf("unmarshaling %s: %v", tc.in, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("unmarshaling %s = %v; not %v", tc.in, got, tc.want)
+		}
+	}
+
+	var bad piazza.Time
+	if err := json.Unmarshal([]byte(`"yesterday"`), &bad); err == nil {
+		t.Error("unmarshaling \"yesterday\" succeeded")
+	}
+
+	buf, err := json.Marshal(struct {
+		A, B piazza.Time
+	}{A: piazza.Time{Time: sept6.In(time.FixedZone("PDT", -7*60*60))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"A":"2016-09-06T20:32:57Z","B":""}`; string(buf) != want {
+		t.Errorf("json.Marshal() = %s; not %s", buf, want)
+	}
+}
@@ -2,6 +2,7 @@ package piazza
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -31,10 +32,15 @@ type HTMLWrapper struct {
 // "piazza://classID/contentID"
 const PiazzaScheme = "piazza"
 
-var urlRegexp = xurls.Strict()
+var urlRegexp = xurls.Strict
 
 // Get makes a request to Piazza.
 func (w *HTMLWrapper) Get(uri string) (string, error) {
+	return w.GetContext(context.Background(), uri)
+}
+
+// GetContext is like Get but aborts when ctx is done.
+func (w *HTMLWrapper) GetContext(ctx context.Context, uri string) (string, error) {
 	u, err := url.Parse(uri)
 	if err != nil {
 		return "", err
@@ -44,7 +50,7 @@ func (w *HTMLWrapper) Get(uri string) (string, error) {
 	}
 
 	if u.Host == "" && len(u.Path) <= 1 {
-		status, err := w.c.UserStatus()
+		status, err := w.c.UserStatusContext(ctx)
 		if err != nil {
 			return "", err
 		}
@@ -67,17 +73,21 @@ func (w *HTMLWrapper) Get(uri string) (string, error) {
 		if !ok {
 			return "", errors.New("need to fetch piazza:// before this")
 		}
-		req, err := http.NewRequest("GET", network.ResourceURL(), nil)
+		req, err := http.NewRequest("GET", w.c.ResourceURL(network), nil)
 		if err != nil {
 			return "", err
 		}
-		resp, err := http.DefaultClient.Do(req)
+		req = req.WithContext(ctx)
+		if len(w.c.ua) > 0 {
+			req.Header.Set("User-Agent", w.c.ua)
+		}
+		resp, err := w.c.http.Do(req)
 		if err != nil {
 			return "", err
 		}
 		defer resp.Body.Close()
 		resources, _ := ioutil.ReadAll(resp.Body)
-		feed, err := w.c.Feed(u.Host)
+		feed, err := w.c.FeedContext(ctx, u.Host)
 		if err != nil {
 			return "", err
 		}
@@ -103,7 +113,7 @@ func (w *HTMLWrapper) Get(uri string) (string, error) {
 		contentID = contentID[1:]
 	}
 
-	post, err := w.c.Content(u.Host, contentID)
+	post, err := w.c.ContentContext(ctx, u.Host, contentID)
 	if err != nil {
 		return "", err
 	}
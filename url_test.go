@@ -5,12 +5,17 @@ import (
 	"reflect"
 	"testing"
 
-	"github.com/mvdan/xurls"
+	"mvdan.cc/xurls"
 )
 
+// clientFromEnv logs into piazza.com as $PIAZZAUSER, skipping the test if
+// there are no credentials so the suite runs offline.
 func clientFromEnv(t *testing.T) *Client {
 	user := os.Getenv("PIAZZAUSER")
 	pass := os.Getenv("PIAZZAPASS")
+	if len(user) == 0 || len(pass) == 0 {
+		t.Skip("PIAZZAUSER and PIAZZAPASS aren't set; skipping live test")
+	}
 	c, err := MakeClient(user, pass)
 	if err != nil {
 		t.Fatal(err)
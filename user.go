@@ -0,0 +1,114 @@
+package piazza
+
+import (
+	"context"
+)
+
+// User is a member of a class as seen by network.get_users.
+type User struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// Role is the user's role in the class, e.g. "student" or "instructor".
+	Role          string   `json:"role"`
+	Photo         string   `json:"photo"`
+	ClassSections []string `json:"class_sections"`
+	Admin         bool     `json:"admin"`
+}
+
+// AnonymousName is the name Author gives anonymous users.
+const AnonymousName = "Anonymous"
+
+// Anonymous reports whether u stands in for an anonymous author.
+func (u User) Anonymous() bool {
+	return len(u.ID) == 0
+}
+
+type usersReq struct {
+	Nid string   `json:"nid"`
+	IDs []string `json:"ids,omitempty"`
+}
+
+type usersResponse struct {
+	Aid    string      `json:"aid"`
+	Error  interface{} `json:"error"`
+	Result []User      `json:"result"`
+}
+
+// cacheUsers remembers users of the class nid.
+func (c *Client) cacheUsers(nid string, users []User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.users == nil {
+		c.users = map[string]map[string]User{}
+	}
+	if c.users[nid] == nil {
+		c.users[nid] = map[string]User{}
+	}
+	for _, u := range users {
+		c.users[nid][u.ID] = u
+	}
+}
+
+// Users looks up members of the class nid by ID. Users are cached per class so
+// only ones that haven't been seen before are requested. IDs Piazza doesn't
+// know are left out of the result, which is otherwise in the order of uids.
+func (c *Client) Users(ctx context.Context, nid string, uids ...string) ([]User, error) {
+	var missing []string
+	c.mu.Lock()
+	for _, uid := range uids {
+		if _, ok := c.users[nid][uid]; !ok && !hasString(missing, uid) {
+			missing = append(missing, uid)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(missing) > 0 {
+		var resp usersResponse
+		if err := c.MakeAPIReqContext(ctx, "network.get_users", usersReq{Nid: nid, IDs: missing}, &resp); err != nil {
+			return nil, err
+		}
+		c.cacheUsers(nid, resp.Result)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var users []User
+	for _, uid := range uids {
+		if u, ok := c.users[nid][uid]; ok {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+// Roster returns everyone in the class nid. It needs the admin_roster or
+// member_roster permission.
+func (c *Client) Roster(ctx context.Context, nid string) ([]User, error) {
+	if _, err := c.checkPermission(ctx, nid, PermAdminRoster, PermMemberRoster); err != nil {
+		return nil, err
+	}
+	var resp usersResponse
+	if err := c.MakeAPIReqContext(ctx, "network.get_all_users", usersReq{Nid: nid}, &resp); err != nil {
+		return nil, err
+	}
+	c.cacheUsers(nid, resp.Result)
+	return resp.Result, nil
+}
+
+// Author resolves the author of a post revision, change log entry or feed log
+// entry given its uid and anon fields. Anonymous authors, and ones Piazza
+// didn't name, come back as a User with no ID named AnonymousName.
+func (c *Client) Author(ctx context.Context, nid, uid, anon string) (User, error) {
+	if len(uid) == 0 || (len(anon) > 0 && anon != string(AnonymousNo)) {
+		return User{Name: AnonymousName}, nil
+	}
+	users, err := c.Users(ctx, nid, uid)
+	if err != nil {
+		return User{}, err
+	}
+	if len(users) == 0 {
+		return User{Name: AnonymousName}, nil
+	}
+	return users[0], nil
+}
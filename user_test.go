@@ -0,0 +1,85 @@
+package piazza_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/piazzatest"
+)
+
+func TestUsers(t *testing.T) {
+	s, c := newFake(t)
+	ctx := context.Background()
+	s.AddUser(piazzatest.User{ID: "u2", Name: "Other Student", Email: "other@example.com"})
+	s.AddNetwork(piazza.Network{ID: "n3"}, "u2")
+
+	users, err := c.Users(ctx, "n1", "u1", "u2", "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].ID != "u1" || users[0].Name != "Some Student" || users[0].Role != "student" || users[1].ID != "u1" {
+		t.Errorf("Users() = %+v; expected u1 twice and not u2, who isn't in the class", users)
+	}
+
+	// Cached users are served without asking the server.
+	s.SetUnavailable("network.get_users", true)
+	if _, err := c.Users(ctx, "n1", "u1"); err != nil {
+		t.Errorf("cached Users() = %v", err)
+	}
+
+	for _, tc := range []struct {
+		uid, anon, name string
+	}{
+		{"u1", "no", "Some Student"},
+		{"u1", "", "Some Student"},
+		{"u1", "stud", piazza.AnonymousName},
+		{"", "no", piazza.AnonymousName},
+	} {
+		u, err := c.Author(ctx, "n1", tc.uid, tc.anon)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Name != tc.name || u.Anonymous() != (tc.name == piazza.AnonymousName) {
+			t.Errorf("Author(%q, %q) = %+v; expected %s", tc.uid, tc.anon, u, tc.name)
+		}
+	}
+}
+
+func TestRoster(t *testing.T) {
+	s, student := newFake(t)
+	ctx := context.Background()
+	var n piazza.Network
+	if err := json.Unmarshal([]byte(`{
+		"id": "n4",
+		"config": {"roles": {"ta": {"admin_roster": true}}}
+	}`), &n); err != nil {
+		t.Fatal(err)
+	}
+	s.AddUser(piazzatest.User{
+		ID:       "t1",
+		Email:    "ta@example.com",
+		Password: "hunter4",
+		Name:     "Some TA",
+		Roles:    map[string]string{"n4": "ta"},
+	})
+	s.AddNetwork(n, "u1", "t1")
+
+	if _, err := student.Roster(ctx, "n4"); !errors.Is(err, piazza.ErrPermissionDenied) {
+		t.Errorf("student Roster() = %v; not %v", err, piazza.ErrPermissionDenied)
+	}
+
+	ta, err := piazza.MakeClientWithOptions("ta@example.com", "hunter4", s.ClientOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	roster, err := ta.Roster(ctx, "n4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roster) != 2 || roster[0].ID != "t1" || !roster[0].Admin || roster[1].ID != "u1" || roster[1].Email != "student@example.com" {
+		t.Errorf("Roster() = %+v", roster)
+	}
+}
@@ -0,0 +1,134 @@
+package watch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/pkg/errors"
+)
+
+// Cursor is what a watcher last saw of a class. Changes are reported relative
+// to it.
+type Cursor struct {
+	// Posts is the last seen state of each post, by ID.
+	Posts map[string]PostCursor `json:"posts"`
+	// Polled is when the feed was last read.
+	Polled piazza.Time `json:"polled"`
+	// Deleted holds the IDs of posts Piazza confirmed deleted. The feed can
+	// lag behind a deletion, so they're neither looked up nor reported
+	// again.
+	Deleted map[string]bool `json:"deleted,omitempty"`
+}
+
+// PostCursor is the last seen state of a post.
+type PostCursor struct {
+	Nr          int      `json:"nr"`
+	Subject     string   `json:"subject"`
+	Folders     []string `json:"folders,omitempty"`
+	MainVersion int      `json:"main_version"`
+	// Log is how many entries of the post's feed log have been seen.
+	Log        int  `json:"log"`
+	Unanswered bool `json:"unanswered"`
+	Good       int  `json:"good"`
+}
+
+func postCursor(item piazza.FeedItem) PostCursor {
+	return PostCursor{
+		Nr:          item.Nr,
+		Subject:     item.Subject,
+		Folders:     item.Folders,
+		MainVersion: item.MainVersion,
+		Log:         len(item.Log),
+		Unanswered:  item.IsUnanswered(),
+		Good:        item.Gd,
+	}
+}
+
+// Cursors is where a watcher keeps its cursors so it can pick up where it left
+// off after a restart.
+type Cursors interface {
+	// Load returns the cursor of the class nid, and false if there isn't
+	// one.
+	Load(nid string) (Cursor, bool, error)
+	// Save replaces the cursor of the class nid.
+	Save(nid string, c Cursor) error
+}
+
+// FileCursors keeps the cursors of every class in one JSON file.
+type FileCursors struct {
+	file string
+	mu   sync.Mutex
+}
+
+var _ Cursors = (*FileCursors)(nil)
+
+// NewFileCursors returns cursors kept in file, which is created when the
+// first cursor is saved.
+func NewFileCursors(file string) *FileCursors {
+	return &FileCursors{file: file}
+}
+
+func (f *FileCursors) read() (map[string]Cursor, error) {
+	cursors := map[string]Cursor{}
+	buf, err := ioutil.ReadFile(f.file)
+	if os.IsNotExist(err) {
+		return cursors, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &cursors); err != nil {
+		return nil, errors.Wrapf(err, "reading cursors from %q", f.file)
+	}
+	return cursors, nil
+}
+
+// Load implements Cursors.
+func (f *FileCursors) Load(nid string) (Cursor, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cursors, err := f.read()
+	if err != nil {
+		return Cursor{}, false, err
+	}
+	c, ok := cursors[nid]
+	return c, ok, nil
+}
+
+// Save implements Cursors. The file is replaced by renaming a new one into
+// place, so it's never left half written.
+func (f *FileCursors) Save(nid string, c Cursor) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cursors, err := f.read()
+	if err != nil {
+		return err
+	}
+	cursors[nid] = c
+	buf, err := json.MarshalIndent(cursors, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.file), ".tmp-cursors-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.file); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+// Package watch polls class feeds and reports what changed as typed events,
+// so bots don't each have to work out what's new since they last looked:
+//
+//	w := watch.New(c, []string{nid}, watch.Options{
+//		Cursors: watch.NewFileCursors("cursors.json"),
+//		Handler: func(e watch.Event) { log.Println(e) },
+//	})
+//	err := w.Run(ctx)
+//
+// Each poll of a feed is compared with a cursor, what was seen of every post
+// the time before. Cursors are saved once the events of a poll have been
+// delivered, so a restarted watcher only reports what it missed.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/pkg/errors"
+)
+
+// EventType is the kind of change an Event reports.
+type EventType string
+
+// The changes a watcher reports.
+const (
+	// NewPost is a post that wasn't in the feed before.
+	NewPost EventType = "new_post"
+	// NewAnswer is a student or instructor answer being written or
+	// rewritten.
+	NewAnswer EventType = "new_answer"
+	// NewFollowup is a new followup discussion or feedback on one.
+	NewFollowup EventType = "new_followup"
+	// PostEdited is an edit to a post or its answers.
+	PostEdited EventType = "post_edited"
+	// PostResolved is a post that was waiting on an answer or had unresolved
+	// followups no longer doing so.
+	PostResolved EventType = "post_resolved"
+	// PostDeleted is a post that's gone from the feed and that Piazza can no
+	// longer find.
+	PostDeleted EventType = "post_deleted"
+	// Endorsed is a post or an answer being marked good.
+	Endorsed EventType = "endorsed"
+)
+
+// logEvents maps the types of feed log entries to the events they cause.
+var logEvents = map[string]EventType{
+	"s_answer":        NewAnswer,
+	"i_answer":        NewAnswer,
+	"followup":        NewFollowup,
+	"feedback":        NewFollowup,
+	"update":          PostEdited,
+	"s_answer_update": PostEdited,
+	"i_answer_update": PostEdited,
+	"tag_good":        Endorsed,
+}
+
+// Event is a change to a post.
+type Event struct {
+	Type EventType
+	// Network is the ID of the class.
+	Network string
+	// Item is the post as the feed shows it. Posts that were deleted are
+	// gone from the feed, so only their ID, Nr, Subject and Folders are set.
+	Item piazza.FeedItem
+	// Entry is the feed log entry the event comes from, if there is one. It
+	// says who did what and when.
+	Entry piazza.FeedLogEntry
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%s: %s @%d %q", e.Network, e.Type, e.Item.Nr, e.Item.Subject)
+}
+
+// Defaults for Options.
+const (
+	DefaultInterval   = time.Minute
+	DefaultMaxBackoff = 30 * time.Minute
+)
+
+// Options controls a Watcher.
+type Options struct {
+	// Interval is the time between polls. It defaults to DefaultInterval.
+	Interval time.Duration
+	// Jitter is the most that's randomly added to each wait, so many
+	// watchers don't poll in lockstep.
+	Jitter time.Duration
+	// MaxBackoff caps the wait between polls after errors, which doubles
+	// from Interval with each poll that fails. It defaults to
+	// DefaultMaxBackoff.
+	MaxBackoff time.Duration
+	// Cursors keeps cursors across restarts. Without it, they're only kept
+	// in memory.
+	Cursors Cursors
+	// Backfill reports every post of a class that has no cursor yet as new.
+	// Otherwise the first poll of a class only records where things stand.
+	Backfill bool
+
+	// Events, if set, receives every event. Sends block until the event is
+	// received or Run's context is done.
+	Events chan<- Event
+	// Handler, if set, is called with every event, one at a time.
+	Handler func(Event)
+
+	// Logf, if set, is called with errors Run recovers from.
+	Logf func(format string, args ...interface{})
+}
+
+func (o Options) logf(format string, args ...interface{}) {
+	if o.Logf != nil {
+		o.Logf(format, args...)
+	}
+}
+
+// Watcher polls the feeds of some classes. Use New to create one.
+type Watcher struct {
+	c        *piazza.Client
+	networks []string
+	opts     Options
+
+	mu       sync.Mutex
+	cursors  map[string]Cursor // by network ID
+	failures int               // polls in a row that failed
+	rand     *rand.Rand
+}
+
+// New returns a watcher of the classes networks that reads them with c.
+func New(c *piazza.Client, networks []string, opts Options) *Watcher {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	return &Watcher{
+		c:        c,
+		networks: networks,
+		opts:     opts,
+		cursors:  map[string]Cursor{},
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Run polls until ctx is done and returns its error. Errors polling are
+// logged and retried with backoff.
+func (w *Watcher) Run(ctx context.Context) error {
+	for {
+		err := w.Poll(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		w.mu.Lock()
+		if err != nil {
+			w.failures++
+			w.opts.logf("watch: %v", err)
+		} else {
+			w.failures = 0
+		}
+		wait := w.wait()
+		w.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// wait returns how long to wait before the next poll. w.mu must be held.
+func (w *Watcher) wait() time.Duration {
+	wait := w.opts.Interval
+	if w.failures > 0 {
+		for i := 0; i < w.failures && wait < w.opts.MaxBackoff; i++ {
+			wait *= 2
+		}
+		if wait > w.opts.MaxBackoff && w.opts.MaxBackoff > w.opts.Interval {
+			wait = w.opts.MaxBackoff
+		}
+	}
+	if w.opts.Jitter > 0 {
+		wait += time.Duration(w.rand.Int63n(int64(w.opts.Jitter)))
+	}
+	return wait
+}
+
+// Poll checks every class once and delivers the events of what changed. A
+// class that fails doesn't stop the others from being checked; the first
+// error is returned.
+func (w *Watcher) Poll(ctx context.Context) error {
+	var firstErr error
+	for _, nid := range w.networks {
+		if err := w.poll(ctx, nid); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "polling %s", nid)
+			}
+		}
+	}
+	return firstErr
+}
+
+// cursor returns the cursor of the class nid, loading it if needed.
+func (w *Watcher) cursor(nid string) (Cursor, bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if c, ok := w.cursors[nid]; ok {
+		return c, true, nil
+	}
+	if w.opts.Cursors == nil {
+		return Cursor{}, false, nil
+	}
+	c, ok, err := w.opts.Cursors.Load(nid)
+	if err != nil || !ok {
+		return Cursor{}, false, err
+	}
+	w.cursors[nid] = c
+	return c, true, nil
+}
+
+func (w *Watcher) poll(ctx context.Context, nid string) error {
+	cursor, ok, err := w.cursor(nid)
+	if err != nil {
+		return err
+	}
+	polled := piazza.Time{Time: time.Now().UTC()}
+	var items []piazza.FeedItem
+	it := w.c.FeedPages(ctx, nid, piazza.FeedOptions{Sort: piazza.SortCreated})
+	for it.Next() {
+		if item := it.Item(); !cursor.Deleted[item.ID] {
+			items = append(items, item)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	kept, deleted, err := w.stillThere(ctx, nid, cursor.Posts, items)
+	if err != nil {
+		return err
+	}
+
+	var events []Event
+	if ok || w.opts.Backfill {
+		seen := map[string]PostCursor{}
+		for id, pc := range cursor.Posts {
+			if _, ok := kept[id]; !ok {
+				seen[id] = pc
+			}
+		}
+		events = diff(nid, seen, items)
+	}
+	for _, e := range events {
+		if err := w.deliver(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	next := Cursor{Posts: kept, Polled: polled, Deleted: map[string]bool{}}
+	for _, item := range items {
+		next.Posts[item.ID] = postCursor(item)
+	}
+	for id := range cursor.Deleted {
+		next.Deleted[id] = true
+	}
+	for _, id := range deleted {
+		next.Deleted[id] = true
+	}
+	w.mu.Lock()
+	w.cursors[nid] = next
+	w.mu.Unlock()
+	if w.opts.Cursors != nil {
+		return w.opts.Cursors.Save(nid, next)
+	}
+	return nil
+}
+
+// stillThere returns the cursors of the posts in seen that are missing from
+// the feed items but that Piazza still has, and the IDs of the ones it
+// confirms deleted. Only those are reported deleted.
+func (w *Watcher) stillThere(ctx context.Context, nid string, seen map[string]PostCursor, items []piazza.FeedItem) (map[string]PostCursor, []string, error) {
+	present := map[string]bool{}
+	for _, item := range items {
+		present[item.ID] = true
+	}
+	kept := map[string]PostCursor{}
+	var deleted []string
+	for id, pc := range seen {
+		if present[id] {
+			continue
+		}
+		gone, err := w.c.PostDeleted(ctx, nid, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if gone {
+			deleted = append(deleted, id)
+		} else {
+			kept[id] = pc
+		}
+	}
+	return kept, deleted, nil
+}
+
+func (w *Watcher) deliver(ctx context.Context, e Event) error {
+	if w.opts.Handler != nil {
+		w.opts.Handler(e)
+	}
+	if w.opts.Events != nil {
+		select {
+		case w.opts.Events <- e:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// diff returns the events that take a class from what was seen of its posts
+// to the feed items. Events are ordered by post number, with deletions last.
+func diff(nid string, seen map[string]PostCursor, items []piazza.FeedItem) []Event {
+	items = append([]piazza.FeedItem{}, items...)
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Nr < items[j].Nr })
+
+	var events []Event
+	add := func(typ EventType, item piazza.FeedItem, entry piazza.FeedLogEntry) {
+		events = append(events, Event{Type: typ, Network: nid, Item: item, Entry: entry})
+	}
+	present := map[string]bool{}
+	for _, item := range items {
+		present[item.ID] = true
+		old, ok := seen[item.ID]
+		if !ok {
+			var entry piazza.FeedLogEntry
+			if len(item.Log) > 0 {
+				entry = item.Log[0]
+			}
+			add(NewPost, item, entry)
+			continue
+		}
+
+		edited, endorsed := false, false
+		if old.Log > len(item.Log) {
+			// The log was cut short; there's no telling what's new in it.
+			old.Log = len(item.Log)
+		}
+		for _, entry := range item.Log[old.Log:] {
+			typ, ok := logEvents[entry.Type]
+			if !ok {
+				continue
+			}
+			edited = edited || typ == PostEdited
+			endorsed = endorsed || typ == Endorsed
+			add(typ, item, entry)
+		}
+		if !edited && item.MainVersion > old.MainVersion {
+			add(PostEdited, item, piazza.FeedLogEntry{})
+		}
+		if !endorsed && item.Gd > old.Good {
+			add(Endorsed, item, piazza.FeedLogEntry{})
+		}
+		if old.Unanswered && !item.IsUnanswered() {
+			add(PostResolved, item, piazza.FeedLogEntry{})
+		}
+	}
+
+	var deleted []Event
+	for id, old := range seen {
+		if !present[id] {
+			item := piazza.FeedItem{ID: id, Nr: old.Nr, Subject: old.Subject, Folders: old.Folders}
+			deleted = append(deleted, Event{Type: PostDeleted, Network: nid, Item: item})
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i].Item.Nr < deleted[j].Item.Nr })
+	return append(events, deleted...)
+}
@@ -0,0 +1,196 @@
+package watch_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/piazzatest"
+	"github.com/d4l3k/piazza-api/watch"
+)
+
+// newFake returns clients for a student and an instructor in class n1, which
+// has a question and a note.
+func newFake(t *testing.T) (*piazzatest.Fixture, *piazza.Client, *piazza.Client) {
+	return piazzatest.NewClass(t, `{
+		"id": "n1",
+		"folders": ["hw1"],
+		"config": {"roles": {
+			"student": {"new_followup": true, "member_answer_endorse": true, "expert_answer_endorse": true},
+			"instructor": {"question_edit": true, "question_delete": true, "new_followup": true}
+		}}
+	}`,
+		`{"id": "q1", "nr": 1, "type": "question", "history": [{"subject": "Why?", "content": "Why?"}]}`,
+		`{"id": "q2", "nr": 2, "type": "note", "history": [{"subject": "Hello", "content": "Hi"}]}`)
+}
+
+// recorder collects events from a Handler.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) handle(e watch.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event := fmt.Sprintf("%s %s", e.Type, e.Item.ID)
+	if e.Type == watch.PostDeleted {
+		event += fmt.Sprint(" ", e.Item.Folders)
+	}
+	r.events = append(r.events, event)
+}
+
+// take returns the events so far and forgets them.
+func (r *recorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func expect(t *testing.T, step string, got []string, want ...string) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s: events = %q; expected %q", step, got, want)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	f, student, instructor := newFake(t)
+	ctx := context.Background()
+	cursors := watch.NewFileCursors(filepath.Join(t.TempDir(), "cursors.json"))
+	var r recorder
+	w := watch.New(student, []string{"n1"}, watch.Options{Cursors: cursors, Handler: r.handle})
+
+	must(t, w.Poll(ctx))
+	expect(t, "first poll", r.take())
+
+	_, err := instructor.AnswerAsInstructor(ctx, "n1", "q1", "Because.")
+	must(t, err)
+	p, err := student.CreatePost(ctx, "n1", piazza.NewPost{Type: piazza.PostQuestion, Subject: "How?", Content: "How?", Folders: []string{"hw1"}})
+	must(t, err)
+	must(t, w.Poll(ctx))
+	expect(t, "answer and new post", r.take(), "new_answer q1", "post_resolved q1", "new_post "+p.ID)
+
+	_, err = student.AddFollowup(ctx, "n1", "q1", "But why?")
+	must(t, err)
+	must(t, student.Endorse(ctx, "n1", "q1", piazza.InstructorAnswer))
+	_, err = instructor.EditPost(ctx, "n1", "q2", "Hello!", "Hi!")
+	must(t, err)
+	must(t, instructor.DeletePost(ctx, "n1", p.ID))
+	must(t, w.Poll(ctx))
+	expect(t, "followup, endorsement, edit and delete", r.take(),
+		"new_followup q1", "endorsed q1", "post_edited q2", "post_deleted "+p.ID+" [hw1]")
+
+	must(t, instructor.MarkResolved(ctx, "n1", "q1"))
+	must(t, w.Poll(ctx))
+	expect(t, "resolved followup", r.take(), "post_resolved q1")
+
+	// A new watcher picks up from the saved cursor.
+	restarted := watch.New(student, []string{"n1"}, watch.Options{Cursors: cursors, Handler: r.handle})
+	must(t, restarted.Poll(ctx))
+	expect(t, "after restart", r.take())
+
+	backfill := watch.New(student, []string{"n1"}, watch.Options{Backfill: true, Handler: r.handle})
+	must(t, backfill.Poll(ctx))
+	expect(t, "backfill", r.take(), "new_post q1", "new_post q2")
+
+	// A feed that lags behind the deletion doesn't bring the post back, and
+	// Piazza isn't asked about it again.
+	c, _, err := cursors.Load("n1")
+	must(t, err)
+	if !c.Deleted[p.ID] {
+		t.Errorf("cursor.Deleted = %v; expected %s", c.Deleted, p.ID)
+	}
+	f.AddPost("n1", p)
+	gets := 0
+	f.OnCall("content.get", func() { gets++ })
+	must(t, restarted.Poll(ctx))
+	expect(t, "deleted post listed again", r.take())
+	if gets != 0 {
+		t.Errorf("content.get called %d times; expected none", gets)
+	}
+}
+
+func TestPollBumpedWhileListing(t *testing.T) {
+	s, student, _ := newFake(t)
+	ctx := context.Background()
+	var r recorder
+	w := watch.New(student, []string{"n1"}, watch.Options{Handler: r.handle})
+	s.SetFeedPageLimit(1)
+	must(t, w.Poll(ctx))
+
+	// q2 is bumped to the top of the feed by update time between the first
+	// and second page.
+	pages := 0
+	s.OnCall("network.get_my_feed", func() {
+		if pages++; pages == 2 {
+			if _, err := student.AddFollowup(ctx, "n1", "q2", "bump"); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	must(t, w.Poll(ctx))
+	expect(t, "bumped while listing", r.take(), "new_followup q2")
+	must(t, w.Poll(ctx))
+	expect(t, "next poll", r.take())
+}
+
+func TestRun(t *testing.T) {
+	s, student, _ := newFake(t)
+	s.SetUnavailable("network.get_my_feed", true)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan watch.Event)
+	errs := make(chan string, 100)
+	w := watch.New(student, []string{"n1"}, watch.Options{
+		Interval:   time.Millisecond,
+		MaxBackoff: 5 * time.Millisecond,
+		Jitter:     time.Millisecond,
+		Backfill:   true,
+		Events:     events,
+		Logf: func(format string, args ...interface{}) {
+			select {
+			case errs <- fmt.Sprintf(format, args...):
+			default:
+			}
+		},
+	})
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	select {
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't report the feed being down")
+	}
+	s.SetUnavailable("network.get_my_feed", false)
+	for _, want := range []string{"q1", "q2"} {
+		select {
+		case e := <-events:
+			if e.Type != watch.NewPost || e.Item.ID != want || e.Network != "n1" {
+				t.Errorf("event = %+v; expected a new post %s", e, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run didn't recover from the feed being down")
+		}
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run() = %v; expected %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,477 @@
+// Package webhook delivers watch events to HTTP endpoints as signed JSON
+// payloads. Each endpoint chooses the classes, folders and kinds of events it
+// gets. Failed deliveries are retried with exponential backoff and, when they
+// keep failing, appended to a dead-letter file so nothing is silently lost.
+//
+// Every request carries the HMAC-SHA256 of its body, keyed with the endpoint's
+// secret, in the SignatureHeader. Receivers check it with Verify.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/watch"
+	"github.com/pkg/errors"
+)
+
+// Headers set on every delivery.
+const (
+	// SignatureHeader is "sha256=" followed by the hex HMAC-SHA256 of the
+	// body.
+	SignatureHeader = "X-Piazza-Signature"
+	// EventHeader is the event type, as in Payload.Event.
+	EventHeader = "X-Piazza-Event"
+	// DeliveryHeader is the payload ID, the same across retries.
+	DeliveryHeader = "X-Piazza-Delivery"
+)
+
+// DefaultEvents are the events an endpoint gets if it doesn't list any.
+var DefaultEvents = []watch.EventType{watch.NewPost, watch.NewAnswer, watch.NewFollowup}
+
+// Endpoint is where to deliver events and which ones.
+type Endpoint struct {
+	URL string `json:"url"`
+	// Secret keys the signature of each delivery.
+	Secret string `json:"secret"`
+	// Networks are the IDs of the classes to deliver events from; all of
+	// them if empty.
+	Networks []string `json:"networks,omitempty"`
+	// Folders limits events to posts in any of these folders.
+	Folders []string `json:"folders,omitempty"`
+	// Events are the event types to deliver. They default to DefaultEvents.
+	Events []watch.EventType `json:"events,omitempty"`
+}
+
+// Wants reports whether the endpoint gets e.
+func (ep Endpoint) Wants(e watch.Event) bool {
+	if len(ep.Networks) > 0 && !contains(ep.Networks, e.Network) {
+		return false
+	}
+	// Deleted posts carry the folders they were last seen in.
+	if len(ep.Folders) > 0 {
+		found := false
+		for _, f := range e.Item.Folders {
+			found = found || contains(ep.Folders, f)
+		}
+		if !found {
+			return false
+		}
+	}
+	events := ep.Events
+	if len(events) == 0 {
+		events = DefaultEvents
+	}
+	for _, typ := range events {
+		if typ == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Config is the endpoints to deliver to, as read by ReadConfig:
+//
+//	{"endpoints": [{"url": "https://example.com/hook", "secret": "...",
+//		"networks": ["idwaq3ryhx2jt"], "folders": ["hw1"], "events": ["new_post"]}]}
+type Config struct {
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// ReadConfig reads a JSON Config from file.
+func ReadConfig(file string) (Config, error) {
+	var c Config
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(buf, &c); err != nil {
+		return c, errors.Wrapf(err, "reading webhook config %q", file)
+	}
+	for i, ep := range c.Endpoints {
+		if len(ep.URL) == 0 {
+			return c, errors.Errorf("webhook config %q: endpoint %d has no url", file, i)
+		}
+	}
+	return c, nil
+}
+
+// Networks returns the IDs of the classes the endpoints want events from, and
+// false if one of them wants every class.
+func (c Config) Networks() ([]string, bool) {
+	var networks []string
+	for _, ep := range c.Endpoints {
+		if len(ep.Networks) == 0 {
+			return nil, false
+		}
+		for _, nid := range ep.Networks {
+			if !contains(networks, nid) {
+				networks = append(networks, nid)
+			}
+		}
+	}
+	return networks, true
+}
+
+// Post is the post a payload is about.
+type Post struct {
+	ID      string   `json:"id"`
+	Nr      int      `json:"nr"`
+	Type    string   `json:"type"`
+	Subject string   `json:"subject"`
+	Snippet string   `json:"snippet"`
+	Folders []string `json:"folders"`
+	URL     string   `json:"url"`
+}
+
+// Payload is the body of a delivery.
+type Payload struct {
+	// ID identifies the event, so receivers can ignore repeats.
+	ID      string          `json:"id"`
+	Event   watch.EventType `json:"event"`
+	Network string          `json:"network"`
+	Post    Post            `json:"post"`
+	// UID is who caused the event, if the feed says.
+	UID string `json:"uid,omitempty"`
+	// When is when the event happened, if the feed says.
+	When piazza.Time `json:"when"`
+}
+
+// NewPayload returns the payload for e, linking to the post on the site at
+// baseURL, or piazza.DefaultBaseURL if it's empty.
+func NewPayload(e watch.Event, baseURL string) Payload {
+	if len(baseURL) == 0 {
+		baseURL = piazza.DefaultBaseURL
+	}
+	folders := e.Item.Folders
+	if folders == nil {
+		folders = []string{}
+	}
+	id := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%s\x00%d",
+		e.Network, e.Item.ID, e.Type, e.Entry.When.Format(time.RFC3339Nano), e.Entry.UID, len(e.Item.Log))))
+	return Payload{
+		ID:      hex.EncodeToString(id[:16]),
+		Event:   e.Type,
+		Network: e.Network,
+		Post: Post{
+			ID:      e.Item.ID,
+			Nr:      e.Item.Nr,
+			Type:    e.Item.Type,
+			Subject: e.Item.Subject,
+			Snippet: e.Item.ContentSnipet,
+			Folders: folders,
+			URL:     fmt.Sprintf("%s/class/%s?cid=%d", strings.TrimSuffix(baseURL, "/"), e.Network, e.Item.Nr),
+		},
+		UID:  e.Entry.UID,
+		When: e.Entry.When,
+	}
+}
+
+// Sign returns the SignatureHeader value of body for secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the SignatureHeader value of body for
+// secret.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Defaults for Options.
+const (
+	DefaultAttempts   = 5
+	DefaultBackoff    = time.Second
+	DefaultMaxBackoff = 5 * time.Minute
+	DefaultQueueSize  = 100
+)
+
+// Options controls a Dispatcher.
+type Options struct {
+	// Attempts is how many times a delivery is tried before it's given up
+	// on. It defaults to DefaultAttempts.
+	Attempts int
+	// Backoff is the wait after the first failed attempt, doubling with each
+	// one after up to MaxBackoff. They default to DefaultBackoff and
+	// DefaultMaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// QueueSize is how many deliveries can wait for each endpoint before
+	// Dispatch blocks. It defaults to DefaultQueueSize.
+	QueueSize int
+	// BaseURL is the site payloads link to posts on. It defaults to
+	// piazza.DefaultBaseURL.
+	BaseURL string
+	// DeadLetter is the file deliveries that were given up on are appended
+	// to, one JSON DeadLetter per line. They're only logged if it's unset.
+	DeadLetter string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Logf, if set, is called with failed attempts.
+	Logf func(format string, args ...interface{})
+}
+
+func (o Options) logf(format string, args ...interface{}) {
+	if o.Logf != nil {
+		o.Logf(format, args...)
+	}
+}
+
+// DeadLetter is a delivery that was given up on.
+type DeadLetter struct {
+	URL      string      `json:"url"`
+	Payload  Payload     `json:"payload"`
+	Attempts int         `json:"attempts"`
+	Error    string      `json:"error"`
+	Failed   piazza.Time `json:"failed"`
+}
+
+// Dispatcher delivers events to endpoints. Each endpoint has a queue that's
+// delivered in order. Use New to create one and Close when done.
+type Dispatcher struct {
+	opts    Options
+	queues  []queue
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	deadMu  sync.Mutex
+	closeMu sync.Mutex
+	closed  bool
+}
+
+type queue struct {
+	endpoint Endpoint
+	payloads chan Payload
+}
+
+// New returns a dispatcher to endpoints and starts delivering.
+func New(endpoints []Endpoint, opts Options) *Dispatcher {
+	if opts.Attempts <= 0 {
+		opts.Attempts = DefaultAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	d := &Dispatcher{opts: opts}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	for _, ep := range endpoints {
+		q := queue{endpoint: ep, payloads: make(chan Payload, opts.QueueSize)}
+		d.queues = append(d.queues, q)
+		d.wg.Add(1)
+		go d.work(q)
+	}
+	return d
+}
+
+// Dispatch queues e for every endpoint that wants it. It blocks while a queue
+// is full; if ctx is done first, the event is dead-lettered.
+func (d *Dispatcher) Dispatch(ctx context.Context, e watch.Event) error {
+	d.closeMu.Lock()
+	defer d.closeMu.Unlock()
+	if d.closed {
+		return errors.New("webhook: dispatch after Close")
+	}
+	payload := NewPayload(e, d.opts.BaseURL)
+	var err error
+	for _, q := range d.queues {
+		if !q.endpoint.Wants(e) {
+			continue
+		}
+		if err == nil {
+			select {
+			case q.payloads <- payload:
+				continue
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		d.dead(q.endpoint, payload, 0, err)
+	}
+	return err
+}
+
+// Close stops accepting events and waits for the queued ones to be delivered.
+// If ctx is done first, deliveries still waiting are dead-lettered.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.closeMu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, q := range d.queues {
+			close(q.payloads)
+		}
+	}
+	d.closeMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) work(q queue) {
+	defer d.wg.Done()
+	for p := range q.payloads {
+		attempts, err := d.deliver(q.endpoint, p)
+		if err != nil {
+			d.dead(q.endpoint, p, attempts, err)
+		}
+	}
+}
+
+// permanent marks errors that retrying won't fix.
+type permanent struct{ error }
+
+// deliver tries to deliver p until it succeeds, fails permanently or runs out
+// of attempts. It returns the number of attempts made.
+func (d *Dispatcher) deliver(ep Endpoint, p Payload) (int, error) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return 0, err
+	}
+	wait := d.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		err := d.post(ep, p, body)
+		if err == nil {
+			return attempt, nil
+		}
+		if _, ok := err.(permanent); ok || attempt >= d.opts.Attempts || d.ctx.Err() != nil {
+			return attempt, err
+		}
+		d.opts.logf("webhook: delivering %s to %s (attempt %d): %v; retrying in %s", p.ID, ep.URL, attempt, err, wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+			return attempt, errors.Wrap(err, "gave up while shutting down")
+		case <-timer.C:
+		}
+		if wait *= 2; wait > d.opts.MaxBackoff {
+			wait = d.opts.MaxBackoff
+		}
+	}
+}
+
+func (d *Dispatcher) post(ep Endpoint, p Payload, body []byte) error {
+	req, err := http.NewRequest("POST", ep.URL, bytes.NewReader(body))
+	if err != nil {
+		return permanent{err}
+	}
+	req = req.WithContext(d.ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "piazza-api-webhook")
+	req.Header.Set(SignatureHeader, Sign(ep.Secret, body))
+	req.Header.Set(EventHeader, string(p.Event))
+	req.Header.Set(DeliveryHeader, p.ID)
+	resp, err := d.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = errors.Errorf("%s responded %s", ep.URL, resp.Status)
+	// Other client errors won't go away by trying again.
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return permanent{err}
+	}
+	return err
+}
+
+func (d *Dispatcher) dead(ep Endpoint, p Payload, attempts int, err error) {
+	if e, ok := err.(permanent); ok {
+		err = e.error
+	}
+	d.opts.logf("webhook: giving up on %s to %s after %d attempts: %v", p.ID, ep.URL, attempts, err)
+	if len(d.opts.DeadLetter) == 0 {
+		return
+	}
+	buf, jerr := json.Marshal(DeadLetter{
+		URL:      ep.URL,
+		Payload:  p,
+		Attempts: attempts,
+		Error:    err.Error(),
+		Failed:   piazza.Time{Time: time.Now().UTC()},
+	})
+	if jerr != nil {
+		d.opts.logf("webhook: %v", jerr)
+		return
+	}
+	d.deadMu.Lock()
+	defer d.deadMu.Unlock()
+	f, ferr := os.OpenFile(d.opts.DeadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if ferr == nil {
+		_, ferr = f.Write(append(buf, '\n'))
+		if cerr := f.Close(); ferr == nil {
+			ferr = cerr
+		}
+	}
+	if ferr != nil {
+		d.opts.logf("webhook: writing dead letter: %v", ferr)
+	}
+}
+
+// ReadDeadLetters reads a dead-letter file.
+func ReadDeadLetters(file string) ([]DeadLetter, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var letters []DeadLetter
+	for i, line := range strings.Split(string(buf), "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		var l DeadLetter
+		if err := json.Unmarshal([]byte(line), &l); err != nil {
+			return nil, errors.Wrapf(err, "reading %q line %d", file, i+1)
+		}
+		letters = append(letters, l)
+	}
+	return letters, nil
+}
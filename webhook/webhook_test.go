@@ -0,0 +1,232 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	piazza "github.com/d4l3k/piazza-api"
+	"github.com/d4l3k/piazza-api/watch"
+	"github.com/d4l3k/piazza-api/webhook"
+)
+
+// receiver is an endpoint that fails the first failures requests with status.
+type receiver struct {
+	*httptest.Server
+	secret   string
+	status   int
+	failures int
+
+	mu       sync.Mutex
+	requests int
+	payloads []webhook.Payload
+	badSigs  int
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	r := &receiver{secret: secret, status: http.StatusInternalServerError}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests++
+		if !webhook.Verify(r.secret, body, req.Header.Get(webhook.SignatureHeader)) {
+			r.badSigs++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.requests <= r.failures {
+			w.WriteHeader(r.status)
+			return
+		}
+		var p webhook.Payload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Error(err)
+		}
+		if got := req.Header.Get(webhook.EventHeader); got != string(p.Event) {
+			t.Errorf("%s = %q; expected %q", webhook.EventHeader, got, p.Event)
+		}
+		if got := req.Header.Get(webhook.DeliveryHeader); got != p.ID {
+			t.Errorf("%s = %q; expected %q", webhook.DeliveryHeader, got, p.ID)
+		}
+		r.payloads = append(r.payloads, p)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) got() ([]webhook.Payload, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.payloads, r.requests
+}
+
+func event(typ watch.EventType, nid string, nr int, folders ...string) watch.Event {
+	return watch.Event{
+		Type:    typ,
+		Network: nid,
+		Item: piazza.FeedItem{
+			ID:      "p" + string(rune('0'+nr)),
+			Nr:      nr,
+			Subject: "Subject",
+			Folders: folders,
+		},
+		Entry: piazza.FeedLogEntry{Type: "create", UID: "u1"},
+	}
+}
+
+func fastOptions(t *testing.T) webhook.Options {
+	return webhook.Options{
+		Backoff:    time.Millisecond,
+		MaxBackoff: 4 * time.Millisecond,
+		DeadLetter: filepath.Join(t.TempDir(), "dead.jsonl"),
+		Logf:       t.Logf,
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	ctx := context.Background()
+	all := newReceiver(t, "s1")
+	all.failures = 2
+	hw := newReceiver(t, "s2")
+
+	opts := fastOptions(t)
+	opts.BaseURL = "https://piazza.example.edu/"
+	d := webhook.New([]webhook.Endpoint{
+		{URL: all.URL, Secret: "s1"},
+		{URL: hw.URL, Secret: "s2", Networks: []string{"n1"}, Folders: []string{"hw1"},
+			Events: []watch.EventType{watch.NewPost, watch.PostEdited, watch.PostDeleted}},
+	}, opts)
+	for _, e := range []watch.Event{
+		event(watch.NewPost, "n1", 1, "hw1"),
+		event(watch.NewAnswer, "n1", 1, "hw1"),
+		event(watch.PostEdited, "n1", 2, "logistics"),
+		event(watch.NewPost, "n2", 3, "hw1"),
+		event(watch.PostEdited, "n1", 1, "hw1"),
+		event(watch.PostDeleted, "n1", 4, "hw1"),
+		event(watch.PostDeleted, "n1", 5, "logistics"),
+	} {
+		if err := d.Dispatch(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads, requests := all.got()
+	if len(payloads) != 3 || requests != 5 {
+		t.Fatalf("default endpoint got %d payloads in %d requests; expected 3 in 5", len(payloads), requests)
+	}
+	for i, want := range []watch.EventType{watch.NewPost, watch.NewAnswer, watch.NewPost} {
+		if payloads[i].Event != want {
+			t.Errorf("default endpoint payload %d = %+v; expected a %s", i, payloads[i], want)
+		}
+	}
+	p := payloads[0]
+	if p.Network != "n1" || p.Post.Nr != 1 || p.UID != "u1" || p.Post.Folders[0] != "hw1" ||
+		p.Post.URL != "https://piazza.example.edu/class/n1?cid=1" {
+		t.Errorf("payload = %+v", p)
+	}
+
+	payloads, _ = hw.got()
+	if len(payloads) != 3 || payloads[0].Event != watch.NewPost || payloads[1].Event != watch.PostEdited ||
+		payloads[2].Event != watch.PostDeleted || payloads[2].Post.Nr != 4 {
+		t.Errorf("filtered endpoint got %+v", payloads)
+	}
+	if _, err := webhook.ReadDeadLetters(opts.DeadLetter); err == nil {
+		t.Errorf("expected no dead letters")
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	down := newReceiver(t, "s1")
+	down.failures = 100
+	rejecting := newReceiver(t, "s2")
+	rejecting.failures = 100
+	rejecting.status = http.StatusBadRequest
+
+	opts := fastOptions(t)
+	opts.Attempts = 3
+	d := webhook.New([]webhook.Endpoint{
+		{URL: down.URL, Secret: "s1"},
+		{URL: rejecting.URL, Secret: "s2"},
+	}, opts)
+	if err := d.Dispatch(ctx, event(watch.NewFollowup, "n1", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Dispatch(ctx, event(watch.NewFollowup, "n1", 1)); err == nil {
+		t.Errorf("expected an error dispatching after Close")
+	}
+
+	if _, requests := down.got(); requests != 3 {
+		t.Errorf("failing endpoint got %d requests; expected 3", requests)
+	}
+	// Client errors aren't retried.
+	if _, requests := rejecting.got(); requests != 1 {
+		t.Errorf("rejecting endpoint got %d requests; expected 1", requests)
+	}
+	letters, err := webhook.ReadDeadLetters(opts.DeadLetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := map[string]int{}
+	for _, l := range letters {
+		attempts[l.URL] = l.Attempts
+		if l.Payload.Event != watch.NewFollowup || l.Error == "" {
+			t.Errorf("dead letter = %+v", l)
+		}
+	}
+	if len(letters) != 2 || attempts[down.URL] != 3 || attempts[rejecting.URL] != 1 {
+		t.Errorf("dead letters = %+v", letters)
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	down := newReceiver(t, "s1")
+	down.failures = 100
+
+	opts := fastOptions(t)
+	opts.Backoff = time.Hour
+	opts.MaxBackoff = time.Hour
+	d := webhook.New([]webhook.Endpoint{{URL: down.URL, Secret: "s1"}}, opts)
+	if err := d.Dispatch(context.Background(), event(watch.NewPost, "n1", 1)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Close() = %v; expected %v", err, context.DeadlineExceeded)
+	}
+	letters, err := webhook.ReadDeadLetters(opts.DeadLetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].Attempts != 1 {
+		t.Errorf("dead letters = %+v", letters)
+	}
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"new_post"}`)
+	sig := webhook.Sign("secret", body)
+	if !webhook.Verify("secret", body, sig) {
+		t.Errorf("Verify rejected its own signature %q", sig)
+	}
+	if webhook.Verify("other", body, sig) || webhook.Verify("secret", []byte(`{}`), sig) {
+		t.Errorf("Verify accepted a bad signature")
+	}
+}